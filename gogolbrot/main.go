@@ -1,137 +1,287 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
-	"image/color"
-	"image/png"
 	"log"
 	"math"
-	"math/cmplx"
+	"math/big"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
-	"sync"
+	"sync/atomic"
 	"time"
+
+	"rustlebrot/pkg/deepzoom"
+	"rustlebrot/pkg/encoder"
+	"rustlebrot/pkg/mandel"
+	"rustlebrot/pkg/palette"
+	"rustlebrot/pkg/scheduler"
+	"rustlebrot/pkg/simd"
+	"rustlebrot/pkg/tileserver"
 )
 
-type FrameJob struct {
-	frame      int
-	xRange     [2]float64
-	yRange     [2]float64
-	zoomFactor float64
-}
+var paletteFlag = flag.String("palette", "hippi", "color palette to render with (see palette.Names, or a path to a palette JSON file)")
+var formatFlag = flag.String("format", "mjpeg", fmt.Sprintf("video encoder backend to use (%v)", encoder.Names()))
+var outFlag = flag.String("out", "", "output video path (defaults based on -format)")
+var simdFlag = flag.String("simd", simd.Auto, "vectorized inner-loop backend: auto, off, avx2, avx512 (falls back to scalar when unavailable)")
 
-func mandelbrot(c complex128, maxIter int) float64 {
-	z := c
-	var n int
-	for ; n < maxIter; n++ {
-		if cmplx.Abs(z) > 2 {
-			break
-		}
-		z = z*z + c
+func defaultOutputPath(format string) string {
+	switch format {
+	case "h264":
+		return "go_out.h264"
+	case "av1":
+		return "go_out.ivf"
+	default:
+		return "go_out.avi"
 	}
-	return float64(n) / float64(maxIter)
 }
 
-func colorGradient(iterRatio float64) color.RGBA {
-	t := uint8(iterRatio * 255)
-	return color.RGBA{t, t, 255 - t, 255}
+func loadPalette(name string) *palette.Palette {
+	if p, ok := palette.Get(name); ok {
+		return p
+	}
+	p, err := palette.Load(name)
+	if err != nil {
+		log.Fatalf("unknown palette %q (not a built-in name or loadable file): %s", name, err)
+	}
+	return p
 }
 
-func renderMandelbrot(width, height, maxIter int, xRange, yRange [2]float64) *image.RGBA {
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-	scalex := (xRange[1] - xRange[0]) / float64(width)
-	scaley := (yRange[1] - yRange[0]) / float64(height)
+// perturbationThreshold is the absolute zoom level past which float64
+// coordinates no longer resolve distinct pixels (roughly 1e15, a couple
+// orders of magnitude below the ~1e-16 precision of a double), so frames
+// switch from direct double-precision iteration to perturbation rendering.
+const perturbationThreshold = 1e15
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			c := complex(float64(x)*scalex+xRange[0], float64(y)*scaley+yRange[0])
-			iterRatio := mandelbrot(c, maxIter)
-			img.Set(x, y, colorGradient(iterRatio))
-		}
+// bigCenterPrecision is the working precision for the arbitrary-precision
+// reference orbit, sized to comfortably exceed the ~200-digit center below.
+const bigCenterPrecision = 1024
+
+// Hard-coded 200-digit deep-zoom center. Kept as strings so they can be
+// parsed at arbitrary precision instead of truncating to a float64.
+const (
+	xCenterStr = "-1.74999841099374081749002483162428393452822172335808534616943930976364725846655540417646727085571962736578151132907961927190726789896685696750162524460775546580822744596887978637416593715319388030232414667046419863755743802804780843375"
+	yCenterStr = "-0.00000000000000165712469295418692325810961981279189026504290127375760405334498110850956047368308707050735960323397389547038231194872482690340369921750514146922400928554011996123112902000856666847088788158433995358406779259404221904755"
+)
+
+func parseBigCenter() (re, im *big.Float) {
+	re, _, err := big.ParseFloat(xCenterStr, 10, bigCenterPrecision, big.ToNearestEven)
+	if err != nil {
+		log.Fatalf("failed to parse deep-zoom center real part: %s", err)
+	}
+	im, _, err = big.ParseFloat(yCenterStr, 10, bigCenterPrecision, big.ToNearestEven)
+	if err != nil {
+		log.Fatalf("failed to parse deep-zoom center imaginary part: %s", err)
+	}
+	return re, im
+}
+
+// mandelbrot iterates z_{n+1} = z_n^2 + c and returns the smooth
+// (renormalized) iteration ratio in [0,1], 1 meaning c never escaped.
+func mandelbrot(c complex128, maxIter int) float64 {
+	n, z := mandel.Iterate(real(c), imag(c), maxIter)
+	if n == maxIter {
+		return 1.0
 	}
-	return img
+	mu := palette.SmoothIterCount(n, z)
+	return math.Min(mu/float64(maxIter), 1.0)
 }
 
-func workerFrame(jobs <-chan FrameJob, maxIter, width, height int) {
-	for j := range jobs {
-		startTime := time.Now()
+// renderMandelbrotRows fills rows [y0,y1) of img (out of totalHeight total
+// rows) via direct double-precision iteration, returning the pixel count
+// and estimated total iteration count for throughput reporting.
+//
+// When simdBackend is active, pixels are iterated Lanes at a time via the
+// simd package; those pixels get the plain n/maxIter ratio rather than the
+// smooth renormalized count, since the vector kernel only reports the
+// escape iteration, not the final z. The scalar remainder (and the whole
+// row when simdBackend is Off) still gets full smooth coloring.
+func renderMandelbrotRows(img *image.RGBA, width, maxIter, totalHeight, y0, y1 int, xRange, yRange [2]float64, pal *palette.Palette, simdBackend string) (pixels int, iterSum float64) {
+	scalex := (xRange[1] - xRange[0]) / float64(width)
+	scaley := (yRange[1] - yRange[0]) / float64(totalHeight)
+
+	for y := y0; y < y1; y++ {
+		rowIm := float64(y)*scaley + yRange[0]
+		x := 0
 
-		img := renderMandelbrot(width, height, maxIter, j.xRange, j.yRange)
+		if simdBackend != simd.Off {
+			for ; x+simd.Lanes <= width; x += simd.Lanes {
+				var cRe, cIm [4]float64
+				for lane := 0; lane < simd.Lanes; lane++ {
+					cRe[lane] = float64(x+lane)*scalex + xRange[0]
+					cIm[lane] = rowIm
+				}
+				counts := simd.Iterate4(simdBackend, cRe, cIm, maxIter)
+				for lane := 0; lane < simd.Lanes; lane++ {
+					ratio := 1.0
+					if counts[lane] < maxIter {
+						ratio = float64(counts[lane]) / float64(maxIter)
+					}
+					img.Set(x+lane, y, pal.Lookup(ratio))
+					pixels++
+					iterSum += float64(counts[lane])
+				}
+			}
+		}
 
-		outputFilename := fmt.Sprintf("mandelbrot_set_%04d.png", j.frame)
-		outputFile, _ := os.Create(outputFilename)
-		defer outputFile.Close()
+		for ; x < width; x++ {
+			c := complex(float64(x)*scalex+xRange[0], rowIm)
+			ratio := mandelbrot(c, maxIter)
+			img.Set(x, y, pal.Lookup(ratio))
+			pixels++
+			iterSum += ratio * float64(maxIter)
+		}
+	}
+	return pixels, iterSum
+}
 
-		png.Encode(outputFile, img)
+// renderDeepZoomRows fills rows [y0,y1) of img via perturbation against a
+// reference orbit already computed for the frame.
+func renderDeepZoomRows(img *image.RGBA, ref *deepzoom.ReferenceOrbit, t deepzoom.Tile, order, maxIter, y0, y1 int, pal *palette.Palette) (pixels int, iterSum float64) {
+	grid := deepzoom.RenderRows(ref, t, order, maxIter, y0, y1)
+	for dy, row := range grid {
+		y := y0 + dy
+		for x, ratio := range row {
+			img.Set(x, y, pal.Lookup(ratio))
+			pixels++
+			iterSum += ratio * float64(maxIter)
+		}
+	}
+	return pixels, iterSum
+}
 
-		elapsedTime := time.Since(startTime)
-		fmt.Printf("Frame %d completed in %v\n", j.frame, elapsedTime)
+// frameParams holds the per-frame rendering parameters decided once when
+// jobs are laid out, ahead of row-granularity scheduling.
+type frameParams struct {
+	xRange, yRange [2]float64
+	precision      int
+	zoom           float64
+}
 
-		xCenter := (j.xRange[0] + j.xRange[1]) / 2.0
-		yCenter := (j.yRange[0] + j.yRange[1]) / 2.0
-		xRangeWidth := (j.xRange[1] - j.xRange[0]) / j.zoomFactor
-		yRangeWidth := (j.yRange[1] - j.yRange[0]) / j.zoomFactor
+// runServe starts the interactive tile server (`rustlebrot serve [addr]`),
+// serving /tile/{z}/{x}/{y}.png from a shared worker pool behind an LRU
+// cache instead of rendering a batch zoom sequence.
+func runServe(args []string) {
+	addr := ":8080"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+	const defaultMaxIter = 1000
+	const cacheTiles = 4096
 
-		j.xRange = [2]float64{xCenter - xRangeWidth/2.0, xCenter + xRangeWidth/2.0}
-		j.yRange = [2]float64{yCenter - yRangeWidth/2.0, yCenter + yRangeWidth/2.0}
+	fmt.Printf("Serving Mandelbrot tiles on %s/tile/{z}/{x}/{y}.png\n", addr)
+	if err := tileserver.ListenAndServe(addr, defaultMaxIter, cacheTiles); err != nil {
+		log.Fatalf("tile server failed: %s", err)
 	}
 }
 
 func main() {
-	maxIter, _ := strconv.Atoi(os.Args[1])
-	zoomStart, _ := strconv.Atoi(os.Args[2])
-	zoomEnd, _ := strconv.Atoi(os.Args[3])
-	zoomFactor, _ := strconv.ParseFloat(os.Args[4], 64)
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+
+	flag.Parse()
+	args := flag.Args()
+	pal := loadPalette(*paletteFlag)
+	simdBackend := simd.Select(*simdFlag)
+
+	maxIter, _ := strconv.Atoi(args[0])
+	zoomStart, _ := strconv.Atoi(args[1])
+	zoomEnd, _ := strconv.Atoi(args[2])
+	zoomFactor, _ := strconv.ParseFloat(args[3], 64)
 
 	width, height := 1200, 1200
-	xCenter := -1.74999841099374081749002483162428393452822172335808534616943930976364725846655540417646727085571962736578151132907961927190726789896685696750162524460775546580822744596887978637416593715319388030232414667046419863755743802804780843375
-	yCenter := -0.00000000000000165712469295418692325810961981279189026504290127375760405334498110850956047368308707050735960323397389547038231194872482690340369921750514146922400928554011996123112902000856666847088788158433995358406779259404221904755
-	initialZoomFactor := math.Pow(zoomFactor, float64(zoomStart))
-	var wg sync.WaitGroup
+	bigCenterRe, bigCenterIm := parseBigCenter()
+	xCenter, _ := bigCenterRe.Float64()
+	yCenter, _ := bigCenterIm.Float64()
 
-	xRange := [2]float64{
-		xCenter - 2.0/initialZoomFactor,
-		xCenter + 2.0/initialZoomFactor,
+	outPath := *outFlag
+	if outPath == "" {
+		outPath = defaultOutputPath(*formatFlag)
 	}
-
-	yRange := [2]float64{
-		yCenter - 2.0/initialZoomFactor,
-		yCenter + 2.0/initialZoomFactor,
+	enc, err := encoder.New(*formatFlag, outPath, width, height, 30)
+	if err != nil {
+		log.Fatalf("%s", err)
 	}
+	seq := encoder.NewSequencer(enc, zoomStart)
 
-	jobs := make(chan FrameJob, zoomEnd-zoomStart+1)
+	frames := make([]int, 0, zoomEnd-zoomStart+1)
+	params := make(map[int]frameParams, zoomEnd-zoomStart+1)
+	imgs := make(map[int]*image.RGBA, zoomEnd-zoomStart+1)
+	refs := make(map[int]*deepzoom.ReferenceOrbit)
+	orders := make(map[int]int)
+	remaining := make(map[int]*int32, zoomEnd-zoomStart+1)
 
 	fmt.Printf("About to construct jobs\n")
 	startTime := time.Now()
 
-	for w := 0; w < runtime.NumCPU(); w++ {
-		wg.Add(1)
-		go func() {
-			workerFrame(jobs, maxIter, width, height)
-			wg.Done()
-		}()
-	}
+	// The reference orbit depends only on the center and maxIter, both
+	// fixed for the whole run (only zoom, and so the series order, varies
+	// per frame), so it's computed once here and shared across every
+	// deep-zoom frame instead of redone per frame.
+	var deepRef *deepzoom.ReferenceOrbit
 
 	for frame := zoomStart; frame <= zoomEnd; frame++ {
-		jobs <- FrameJob{frame, xRange, yRange, zoomFactor}
+		precision := 64
+		zoom := math.Pow(zoomFactor, float64(frame))
+		if zoom > perturbationThreshold {
+			precision = bigCenterPrecision
+		}
+
+		xRange := [2]float64{xCenter - 2.0/zoom, xCenter + 2.0/zoom}
+		yRange := [2]float64{yCenter - 2.0/zoom, yCenter + 2.0/zoom}
+
+		frames = append(frames, frame)
+		params[frame] = frameParams{xRange: xRange, yRange: yRange, precision: precision, zoom: zoom}
+		imgs[frame] = image.NewRGBA(image.Rect(0, 0, width, height))
+		rows := int32(height)
+		remaining[frame] = &rows
+
+		if precision > 64 {
+			if deepRef == nil {
+				deepRef = deepzoom.ComputeReferenceOrbit(bigCenterRe, bigCenterIm, maxIter)
+			}
+			t := deepzoom.Tile{Width: width, Height: height, CenterRe: bigCenterRe, CenterIm: bigCenterIm, Zoom: zoom}
+			refs[frame] = deepRef
+			orders[frame] = deepzoom.ChooseSeriesOrder(deepRef, t, maxIter)
+		}
 	}
 
-	close(jobs)
+	render := func(job scheduler.RowJob) (int, int64) {
+		p := params[job.Frame]
+		img := imgs[job.Frame]
+
+		var pixels int
+		var iterSum float64
+		if p.precision > 64 {
+			t := deepzoom.Tile{Width: width, Height: height, CenterRe: bigCenterRe, CenterIm: bigCenterIm, Zoom: p.zoom}
+			pixels, iterSum = renderDeepZoomRows(img, refs[job.Frame], t, orders[job.Frame], maxIter, job.Y, job.Y+job.Height, pal)
+		} else {
+			pixels, iterSum = renderMandelbrotRows(img, width, maxIter, height, job.Y, job.Y+job.Height, p.xRange, p.yRange, pal, simdBackend)
+		}
+
+		if atomic.AddInt32(remaining[job.Frame], -int32(job.Height)) == 0 {
+			if err := seq.Submit(job.Frame, img); err != nil {
+				log.Fatalf("failed to encode frame %d: %s", job.Frame, err)
+			}
+			fmt.Printf("Frame %d completed\n", job.Frame)
+		}
+		return pixels, int64(iterSum)
+	}
 
-	wg.Wait()
+	sched := scheduler.New(runtime.NumCPU(), 8, 128, maxIter)
+	stats := sched.Run(frames, height, render)
 
 	elapsedTime := time.Since(startTime)
 	fmt.Printf("%d frames completed in %v\n", zoomEnd-zoomStart+1, elapsedTime)
 	fmt.Printf("Average time per frame: %f ms.\n", float64(elapsedTime.Milliseconds())/float64(zoomEnd-zoomStart+1))
+	fmt.Printf("Throughput: %.0f pixels/sec, %.1f avg iterations/pixel\n", stats.PixelsPerSec(), stats.AvgIterPerPixel())
 
-	fmt.Printf("Jobs finished. About to render mp4\n")
-
-	cmd := exec.Command("ffmpeg", "-y", "-framerate", "30", "-i", "go_data/mandelbrot_set_%04d.png", "-c:v", "libx264", "-pix_fmt", "yuv420p", "go_out.mp4")
-	err := cmd.Run()
-	if err != nil {
-		log.Fatalf("Failed to execute command: %s", err)
+	if err := seq.Close(); err != nil {
+		log.Fatalf("failed to finalize %s: %s", outPath, err)
 	}
+	fmt.Printf("Wrote %s\n", outPath)
 }
@@ -0,0 +1,292 @@
+// Package deepzoom implements Pauldelbrot-style perturbation rendering with
+// series approximation, letting Mandelbrot zooms go past the ~1e-15 limit
+// where plain double-precision iteration degenerates into noise.
+//
+// The approach: iterate one high-precision "reference" orbit Z_n at the
+// zoom center using math/big, then for every other pixel iterate only the
+// delta between that pixel and the reference (e_n = z_n - Z_n) in ordinary
+// float64 arithmetic. Deltas stay small near the reference, so double
+// precision is enough even when the absolute coordinates are not
+// representable in a float64. A degree-3 series approximation lets most
+// pixels skip the first several hundred (or thousand) iterations outright.
+package deepzoom
+
+import (
+	"math/big"
+	"math/cmplx"
+
+	"rustlebrot/pkg/palette"
+)
+
+// defaultPrecBits is the minimum working precision for the reference orbit
+// when the caller's big.Float center doesn't already carry more.
+const defaultPrecBits = 200
+
+// GlitchTolerance is Pauldelbrot's glitch-detection threshold: a pixel is
+// considered mis-referenced once |Z_n + e_n| drops below tolerance*|Z_n|,
+// i.e. the delta orbit has collapsed onto the reference and can no longer
+// distinguish escape behavior.
+const GlitchTolerance = 1e-6
+
+// bailout is the escape radius used for every perturbed pixel, matching the
+// classic |z| > 2 Mandelbrot criterion. The reference orbit itself is
+// carried further, until |Z_n| exceeds 1e8, so pixels can keep iterating
+// against it after the reference passes radius 2.
+const bailout = 2.0
+
+// seriesErrorTolerance bounds how far the series-approximated delta may
+// drift from the true (directly-iterated) delta, relative to bailout,
+// before chooseSeriesOrder refuses to skip any further: past this point the
+// series coefficients have started to diverge and a skipped pixel's escape
+// test is no longer trustworthy.
+const seriesErrorTolerance = 1e-9
+
+// ReferenceOrbit holds the double-precision projection of a high-precision
+// orbit Z_n = Z_{n-1}^2 + C0, plus per-iteration series-approximation
+// coefficients for delta_c -> delta_z.
+type ReferenceOrbit struct {
+	Z           []complex128 // double-precision projection of each Z_n
+	A, B, C     []complex128 // e_n ~= A_n*d + B_n*d^2 + C_n*d^3
+	EscapeIndex int          // iteration the reference itself escaped at (len(Z) if never)
+}
+
+// ComputeReferenceOrbit iterates z_{n+1} = z_n^2 + c at the given center
+// using big.Float arithmetic, recording a double-precision projection of
+// each iterate together with the series coefficients needed to skip early
+// iterations for pixels near the center.
+func ComputeReferenceOrbit(centerRe, centerIm *big.Float, maxIter int) *ReferenceOrbit {
+	prec := centerRe.Prec()
+	if prec < defaultPrecBits {
+		prec = defaultPrecBits
+	}
+
+	zr := new(big.Float).SetPrec(prec)
+	zi := new(big.Float).SetPrec(prec)
+	two := new(big.Float).SetPrec(prec).SetInt64(2)
+	tr := new(big.Float).SetPrec(prec)
+	ti := new(big.Float).SetPrec(prec)
+
+	orbit := &ReferenceOrbit{
+		Z: make([]complex128, 0, maxIter),
+		A: make([]complex128, 0, maxIter),
+		B: make([]complex128, 0, maxIter),
+		C: make([]complex128, 0, maxIter),
+	}
+
+	a, b, c := complex(0, 0), complex(0, 0), complex(0, 0) // e_0 = 0
+
+	for n := 0; n < maxIter; n++ {
+		re64, _ := zr.Float64()
+		im64, _ := zi.Float64()
+		Zn := complex(re64, im64)
+
+		orbit.Z = append(orbit.Z, Zn)
+		orbit.A = append(orbit.A, a)
+		orbit.B = append(orbit.B, b)
+		orbit.C = append(orbit.C, c)
+
+		if cmplx.Abs(Zn) > 1e8 {
+			orbit.EscapeIndex = n
+			return orbit
+		}
+
+		a, b, c = 2*Zn*a+1, 2*Zn*b+a*a, 2*Zn*c+2*a*b
+
+		tr.Mul(zr, zr)
+		ti.Mul(zi, zi)
+		tr.Sub(tr, ti)
+		tr.Add(tr, centerRe)
+
+		ti.Mul(zr, zi)
+		ti.Mul(ti, two)
+		ti.Add(ti, centerIm)
+
+		zr.Set(tr)
+		zi.Set(ti)
+	}
+	orbit.EscapeIndex = maxIter
+	return orbit
+}
+
+// SeriesSkip evaluates the degree-3 series approximation at iteration n to
+// estimate e_n directly from deltaC, letting the caller start IterateDelta
+// partway through the orbit instead of from n=0.
+func (r *ReferenceOrbit) SeriesSkip(n int, deltaC complex128) complex128 {
+	return r.A[n]*deltaC + r.B[n]*deltaC*deltaC + r.C[n]*deltaC*deltaC*deltaC
+}
+
+// IterateDelta iterates the perturbation recurrence
+// e_{n+1} = 2*Z_n*e_n + e_n^2 + deltaC for a single pixel against ref,
+// starting at iteration skip with e already populated (typically via
+// SeriesSkip). It returns the escape iteration (maxIter if it never
+// escapes; if the reference orbit ends before maxIter, the reference's
+// EscapeIndex, reported as a glitch), the full pixel value Z_n+e_n at
+// that iteration (for smooth/renormalized coloring; meaningless when the
+// pixel never escaped), and whether a glitch was detected.
+func IterateDelta(ref *ReferenceOrbit, deltaC, e complex128, skip, maxIter int) (iter int, full complex128, glitched bool) {
+	limit := maxIter
+	if ref.EscapeIndex < limit {
+		limit = ref.EscapeIndex
+	}
+	for n := skip; n < limit; n++ {
+		Zn := ref.Z[n]
+		z := Zn + e
+		if cmplx.Abs(z) > bailout {
+			return n, z, false
+		}
+		if cmplx.Abs(z) < GlitchTolerance*cmplx.Abs(Zn) {
+			return n, z, true
+		}
+		e = 2*Zn*e + e*e + deltaC
+	}
+	if limit < maxIter {
+		return limit, 0, true // reference escaped before the pixel did: needs re-referencing
+	}
+	return maxIter, 0, false
+}
+
+// ChooseSeriesOrder probes the tile's four corner deltas and returns the
+// largest N for which the series approximation still agrees with direct
+// (unskipped) iteration, so most pixels in the tile can start at N instead
+// of at 0. Callers that render a tile across many row batches should call
+// this once per reference orbit and reuse the result, rather than
+// recomputing it per batch.
+func ChooseSeriesOrder(ref *ReferenceOrbit, t Tile, maxIter int) int {
+	corners := []complex128{
+		t.pixelDelta(0, 0), t.pixelDelta(t.Width-1, 0),
+		t.pixelDelta(0, t.Height-1), t.pixelDelta(t.Width-1, t.Height-1),
+	}
+	return chooseSeriesOrder(ref, corners, maxIter)
+}
+
+// chooseSeriesOrder walks n upward from 1 (e_0 = 0 for every pixel
+// trivially, so order 0 is always valid and needs no check), tracking each
+// corner's true, unskipped delta orbit incrementally - O(1) per corner per
+// step, rather than re-running IterateDelta from scratch for every
+// candidate n, which made the search O(maxIter^2) - and accepts n only as
+// long as every corner's series estimate still agrees with that true delta
+// within seriesErrorTolerance, and no corner has escaped yet.
+//
+// Growing n past the point a corner actually escapes, or past where its
+// series estimate has diverged from the true orbit, would make that
+// corner's series-skipped render start from a delta that no longer
+// corresponds to anything on its real trajectory: the original "this
+// corner escapes before n, so it's moot" logic kept raising n anyway once
+// that happened, silently handing out orders the series could no longer
+// back up. Both conditions now stop the search at the last order known
+// good for every corner.
+func chooseSeriesOrder(ref *ReferenceOrbit, corners []complex128, maxIter int) int {
+	limit := len(ref.Z)
+	if maxIter < limit {
+		limit = maxIter
+	}
+	if limit <= 1 {
+		return 0
+	}
+
+	// e[i] tracks corner i's true e_n, advanced one step at a time as n
+	// grows; it starts at e_0 = 0 and is brought forward to e_1 using Z_0
+	// before the loop below begins comparing at n=1.
+	e := make([]complex128, len(corners))
+	Z0 := ref.Z[0]
+	for i, deltaC := range corners {
+		e[i] = 2*Z0*e[i] + e[i]*e[i] + deltaC
+	}
+
+	best := 0
+	for n := 1; n < limit; n++ {
+		Zn := ref.Z[n]
+		for i, deltaC := range corners {
+			z := Zn + e[i]
+			if cmplx.Abs(z) > bailout {
+				return best // this corner has genuinely escaped: no larger order is valid for it
+			}
+			approx := ref.SeriesSkip(n, deltaC)
+			if cmplx.Abs(approx-e[i]) > seriesErrorTolerance*bailout {
+				return best // series estimate has diverged from the true delta orbit
+			}
+			e[i] = 2*Zn*e[i] + e[i]*e[i] + deltaC
+		}
+		best = n
+	}
+	return best
+}
+
+// Tile describes the pixel rectangle to render and the region of the
+// complex plane it covers, relative to a high-precision center.
+type Tile struct {
+	Width, Height int
+	CenterRe      *big.Float
+	CenterIm      *big.Float
+	Zoom          float64 // half-width of the rendered region is 2/Zoom
+}
+
+func (t Tile) pixelDelta(x, y int) complex128 {
+	scale := 4.0 / t.Zoom
+	dx := (float64(x)/float64(t.Width) - 0.5) * scale
+	dy := (float64(y)/float64(t.Height) - 0.5) * scale
+	return complex(dx, dy)
+}
+
+// RenderRows renders rows [y0,y1) of t (an iteration-count grid, each entry
+// in [0,1], indexed [row][col]) using perturbation against ref at the given
+// series order, re-referencing any pixel that glitches. ref and order are
+// computed once per tile (via ComputeReferenceOrbit/ChooseSeriesOrder) and
+// reused across row batches, so splitting a tile into many row jobs doesn't
+// multiply the cost of the high-precision reference orbit.
+func RenderRows(ref *ReferenceOrbit, t Tile, order, maxIter, y0, y1 int) [][]float64 {
+	prec := t.CenterRe.Prec()
+	result := make([][]float64, y1-y0)
+	type glitchPixel struct{ x, y int }
+	var glitched []glitchPixel
+
+	for y := y0; y < y1; y++ {
+		row := make([]float64, t.Width)
+		for x := 0; x < t.Width; x++ {
+			deltaC := t.pixelDelta(x, y)
+			e := ref.SeriesSkip(order, deltaC)
+			iter, z, glitch := IterateDelta(ref, deltaC, e, order, maxIter)
+			if glitch {
+				glitched = append(glitched, glitchPixel{x, y})
+				continue
+			}
+			row[x] = smoothRatio(iter, z, maxIter)
+		}
+		result[y-y0] = row
+	}
+
+	for _, px := range glitched {
+		deltaC := t.pixelDelta(px.x, px.y)
+		pixRe := new(big.Float).SetPrec(prec).Add(t.CenterRe, big.NewFloat(real(deltaC)))
+		pixIm := new(big.Float).SetPrec(prec).Add(t.CenterIm, big.NewFloat(imag(deltaC)))
+		localRef := ComputeReferenceOrbit(pixRe, pixIm, maxIter)
+		iter, z, _ := IterateDelta(localRef, 0, 0, 0, maxIter)
+		result[px.y-y0][px.x] = smoothRatio(iter, z, maxIter)
+	}
+
+	return result
+}
+
+// smoothRatio renormalizes an escape iteration and its final pixel value
+// into the same banding-free [0,1] ratio palette.SmoothIterCount produces
+// for the direct-iteration and tile-server renderers, so deep-zoom frames
+// get identical coloring treatment instead of the raw, banded iter/maxIter
+// ratio.
+func smoothRatio(iter int, z complex128, maxIter int) float64 {
+	if iter >= maxIter {
+		return 1.0
+	}
+	mu := palette.SmoothIterCount(iter, z)
+	if mu > float64(maxIter) {
+		mu = float64(maxIter)
+	}
+	return mu / float64(maxIter)
+}
+
+// RenderTile renders the whole tile in one call, for callers that don't
+// need row-granularity scheduling.
+func RenderTile(t Tile, maxIter int) [][]float64 {
+	ref := ComputeReferenceOrbit(t.CenterRe, t.CenterIm, maxIter)
+	order := ChooseSeriesOrder(ref, t, maxIter)
+	return RenderRows(ref, t, order, maxIter, 0, t.Height)
+}
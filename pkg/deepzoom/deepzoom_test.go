@@ -0,0 +1,160 @@
+package deepzoom
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestComputeReferenceOrbitStaysBoundedInsideSet(t *testing.T) {
+	ref := ComputeReferenceOrbit(big.NewFloat(0), big.NewFloat(0), 200)
+	if ref.EscapeIndex != 200 {
+		t.Errorf("EscapeIndex = %d, want 200 (c=0 never escapes)", ref.EscapeIndex)
+	}
+}
+
+func TestComputeReferenceOrbitDetectsEscape(t *testing.T) {
+	ref := ComputeReferenceOrbit(big.NewFloat(2), big.NewFloat(2), 200)
+	if ref.EscapeIndex >= 200 {
+		t.Errorf("EscapeIndex = %d, want < 200 (c=2+2i escapes quickly)", ref.EscapeIndex)
+	}
+}
+
+// TestSeriesSkipAgreesWithDirectIteration checks that starting IterateDelta
+// from SeriesSkip's estimate at the series order ChooseSeriesOrder picked
+// lands on the same escape iteration as iterating the same delta from
+// scratch (skip=0, e=0) - the property the series approximation exists to
+// preserve while skipping the early iterations it replaces.
+func TestSeriesSkipAgreesWithDirectIteration(t *testing.T) {
+	centerRe := big.NewFloat(-0.5)
+	centerIm := big.NewFloat(0)
+	const maxIter = 500
+
+	ref := ComputeReferenceOrbit(centerRe, centerIm, maxIter)
+	tile := Tile{Width: 64, Height: 64, CenterRe: centerRe, CenterIm: centerIm, Zoom: 1e6}
+	order := ChooseSeriesOrder(ref, tile, maxIter)
+	if order == 0 {
+		t.Fatal("ChooseSeriesOrder picked order 0; this tile's zoom doesn't exercise the series path")
+	}
+
+	deltas := []complex128{
+		tile.pixelDelta(0, 0),
+		tile.pixelDelta(tile.Width-1, 0),
+		tile.pixelDelta(0, tile.Height-1),
+		tile.pixelDelta(tile.Width-1, tile.Height-1),
+		tile.pixelDelta(tile.Width/2, tile.Height/2),
+	}
+
+	for _, deltaC := range deltas {
+		direct, _, directGlitch := IterateDelta(ref, deltaC, 0, 0, maxIter)
+		if directGlitch {
+			continue // reference itself escaped before this pixel did; not what's under test
+		}
+
+		approx := ref.SeriesSkip(order, deltaC)
+		skipped, _, glitch := IterateDelta(ref, deltaC, approx, order, maxIter)
+		if glitch {
+			t.Errorf("delta %v: series-skip iteration glitched immediately at order %d", deltaC, order)
+			continue
+		}
+		if skipped != direct {
+			t.Errorf("delta %v: series-skip escape iteration %d != direct escape iteration %d (order %d)", deltaC, skipped, direct, order)
+		}
+	}
+}
+
+// TestChooseSeriesOrderAgreesNearBoundaryAtHighZoom exercises a real
+// near-boundary center (Seahorse Valley, where the series coefficients grow
+// large enough to previously overflow past bailout and masquerade as a
+// non-glitch) at a zoom deep enough to need the series path. Unlike
+// TestSeriesSkipAgreesWithDirectIteration's -0.5+0i fixture, whose
+// coefficients stay small (A[499]~=0.58) and so never exercised the
+// divergence check, this asserts both that chooseSeriesOrder stops well
+// short of maxIter once the series actually diverges and that the order it
+// does pick still agrees with direct, from-scratch iteration.
+func TestChooseSeriesOrderAgreesNearBoundaryAtHighZoom(t *testing.T) {
+	const precisionBits = 200
+	centerRe, _, err := big.ParseFloat("-0.743643887037158704752191506114774", 10, precisionBits, big.ToNearestEven)
+	if err != nil {
+		t.Fatalf("parsing centerRe: %s", err)
+	}
+	centerIm, _, err := big.ParseFloat("0.131825904205311970493132056385139", 10, precisionBits, big.ToNearestEven)
+	if err != nil {
+		t.Fatalf("parsing centerIm: %s", err)
+	}
+	const maxIter = 2000
+
+	ref := ComputeReferenceOrbit(centerRe, centerIm, maxIter)
+	tile := Tile{Width: 64, Height: 64, CenterRe: centerRe, CenterIm: centerIm, Zoom: 1e12}
+	order := ChooseSeriesOrder(ref, tile, maxIter)
+
+	if order == 0 {
+		t.Fatal("ChooseSeriesOrder picked order 0; this tile's zoom doesn't exercise the series path")
+	}
+	if order >= maxIter-1 {
+		t.Fatalf("ChooseSeriesOrder picked order %d (maxIter %d): the series coefficients diverge well before maxIter at this boundary center, so growth should have been bounded long before it", order, maxIter)
+	}
+
+	deltas := []complex128{
+		tile.pixelDelta(0, 0),
+		tile.pixelDelta(tile.Width-1, 0),
+		tile.pixelDelta(0, tile.Height-1),
+		tile.pixelDelta(tile.Width-1, tile.Height-1),
+		tile.pixelDelta(tile.Width/2, tile.Height/2),
+		tile.pixelDelta(tile.Width/4, 3*tile.Height/4),
+	}
+
+	for _, deltaC := range deltas {
+		direct, _, directGlitch := IterateDelta(ref, deltaC, 0, 0, maxIter)
+		if directGlitch {
+			continue // reference itself escaped before this pixel did; not what's under test
+		}
+
+		approx := ref.SeriesSkip(order, deltaC)
+		skipped, _, glitch := IterateDelta(ref, deltaC, approx, order, maxIter)
+		if glitch {
+			t.Errorf("delta %v: series-skip iteration glitched immediately at order %d", deltaC, order)
+			continue
+		}
+		if skipped != direct {
+			t.Errorf("delta %v: series-skip escape iteration %d != direct escape iteration %d (order %d)", deltaC, skipped, direct, order)
+		}
+	}
+}
+
+// TestRenderRowsUsesSmoothColoring checks that RenderRows' output ratios
+// come from palette.SmoothIterCount, not the raw (banded) iter/maxIter
+// ratio, matching the coloring mandelbrot() and tileserver's mandelbrot()
+// already apply to their own escape counts.
+func TestRenderRowsUsesSmoothColoring(t *testing.T) {
+	centerRe := big.NewFloat(-0.5)
+	centerIm := big.NewFloat(0.6)
+	const maxIter = 100
+
+	ref := ComputeReferenceOrbit(centerRe, centerIm, maxIter)
+	tile := Tile{Width: 8, Height: 8, CenterRe: centerRe, CenterIm: centerIm, Zoom: 3}
+	order := ChooseSeriesOrder(ref, tile, maxIter)
+
+	grid := RenderRows(ref, tile, order, maxIter, 0, tile.Height)
+
+	sawEscaped := false
+	for y, row := range grid {
+		for x, ratio := range row {
+			if ratio >= 1.0 {
+				continue
+			}
+			deltaC := tile.pixelDelta(x, y)
+			e := ref.SeriesSkip(order, deltaC)
+			iter, _, glitch := IterateDelta(ref, deltaC, e, order, maxIter)
+			if glitch {
+				continue
+			}
+			sawEscaped = true
+			if rawRatio := float64(iter) / float64(maxIter); ratio == rawRatio {
+				t.Errorf("pixel (%d,%d): ratio %v equals the raw iter/maxIter ratio %v; smooth coloring doesn't look applied", x, y, ratio, rawRatio)
+			}
+		}
+	}
+	if !sawEscaped {
+		t.Fatal("no escaping, non-glitched pixel found in this tile; fixture doesn't exercise smooth coloring")
+	}
+}
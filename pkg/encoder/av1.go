@@ -0,0 +1,185 @@
+//go:build cgo && av1
+
+package encoder
+
+/*
+#cgo pkg-config: aom
+#include <aom/aom_encoder.h>
+#include <aom/aomcx.h>
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+	"unsafe"
+)
+
+func init() {
+	registerBackend("av1", newAV1Encoder)
+}
+
+// av1Encoder encodes frames to AV1 via libaom, writing them into an IVF
+// container (the simplest container aom tooling understands without an
+// mp4 muxer).
+type av1Encoder struct {
+	out           *os.File
+	ctx           C.aom_codec_ctx_t
+	img           *C.aom_image_t
+	width, height int
+	frameIndex    int64
+}
+
+func newAV1Encoder(path string, width, height, fps int) (Encoder, error) {
+	iface := C.aom_codec_av1_cx()
+
+	var cfg C.aom_codec_enc_cfg_t
+	if C.aom_codec_enc_config_default(iface, &cfg, 0) != C.AOM_CODEC_OK {
+		return nil, fmt.Errorf("aom: failed to get default config")
+	}
+	cfg.g_w = C.uint(width)
+	cfg.g_h = C.uint(height)
+	cfg.g_timebase.num = 1
+	cfg.g_timebase.den = C.int(fps)
+
+	var ctx C.aom_codec_ctx_t
+	if C.aom_codec_enc_init_ver(&ctx, iface, &cfg, 0, C.AOM_ENCODER_ABI_VERSION) != C.AOM_CODEC_OK {
+		return nil, fmt.Errorf("aom: failed to open encoder")
+	}
+
+	img := C.aom_img_alloc(nil, C.AOM_IMG_FMT_I420, C.uint(width), C.uint(height), 1)
+	if img == nil {
+		C.aom_codec_destroy(&ctx)
+		return nil, fmt.Errorf("aom: failed to allocate image")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		C.aom_img_free(img)
+		C.aom_codec_destroy(&ctx)
+		return nil, err
+	}
+
+	e := &av1Encoder{out: f, ctx: ctx, img: img, width: width, height: height}
+	if err := e.writeIVFHeader(fps); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *av1Encoder) writeIVFHeader(fps int) error {
+	var hdr [32]byte
+	copy(hdr[0:4], "DKIF")
+	binary.LittleEndian.PutUint16(hdr[4:6], 0)  // version
+	binary.LittleEndian.PutUint16(hdr[6:8], 32) // header size
+	copy(hdr[8:12], "AV01")
+	binary.LittleEndian.PutUint16(hdr[12:14], uint16(e.width))
+	binary.LittleEndian.PutUint16(hdr[14:16], uint16(e.height))
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(fps))
+	binary.LittleEndian.PutUint32(hdr[20:24], 1)
+	// frame count at [24:28] is unknown up front; left 0 (many decoders ignore it).
+	_, err := e.out.Write(hdr[:])
+	return err
+}
+
+func (e *av1Encoder) writeIVFFrame(data []byte, pts int64) error {
+	var frameHdr [12]byte
+	binary.LittleEndian.PutUint32(frameHdr[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint64(frameHdr[4:12], uint64(pts))
+	if _, err := e.out.Write(frameHdr[:]); err != nil {
+		return err
+	}
+	_, err := e.out.Write(data)
+	return err
+}
+
+func rgbaToI420Aom(img *image.RGBA, dst *C.aom_image_t, width, height int) {
+	strideY := int(dst.stride[0])
+	strideU := int(dst.stride[1])
+	strideV := int(dst.stride[2])
+
+	yPlane := unsafe.Slice((*C.uint8_t)(unsafe.Pointer(dst.planes[0])), strideY*height)
+	uPlane := unsafe.Slice((*C.uint8_t)(unsafe.Pointer(dst.planes[1])), strideU*height/2)
+	vPlane := unsafe.Slice((*C.uint8_t)(unsafe.Pointer(dst.planes[2])), strideV*height/2)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px := img.RGBAAt(x, y)
+			yy := 0.299*float64(px.R) + 0.587*float64(px.G) + 0.114*float64(px.B)
+			yPlane[y*strideY+x] = C.uint8_t(clamp255(yy))
+
+			if x%2 == 0 && y%2 == 0 {
+				u := -0.169*float64(px.R) - 0.331*float64(px.G) + 0.5*float64(px.B) + 128
+				v := 0.5*float64(px.R) - 0.419*float64(px.G) - 0.081*float64(px.B) + 128
+				uPlane[(y/2)*strideU+x/2] = C.uint8_t(clamp255(u))
+				vPlane[(y/2)*strideV+x/2] = C.uint8_t(clamp255(v))
+			}
+		}
+	}
+}
+
+func (e *av1Encoder) WriteFrame(img *image.RGBA) error {
+	rgbaToI420Aom(img, e.img, e.width, e.height)
+
+	if C.aom_codec_encode(&e.ctx, e.img, C.aom_codec_pts_t(e.frameIndex), 1, 0) != C.AOM_CODEC_OK {
+		return fmt.Errorf("aom: encode failed")
+	}
+	e.frameIndex++
+	return e.drainPackets()
+}
+
+// aomFramePkt mirrors the "frame" arm of the aom_codec_cx_pkt_t union
+// (the C `data` member), which cgo exposes only as an opaque byte array.
+type aomFramePkt struct {
+	buf      unsafe.Pointer
+	sz       C.size_t
+	pts      C.aom_codec_pts_t
+	duration C.ulong
+	flags    C.aom_codec_frame_flags_t
+}
+
+func (e *av1Encoder) drainPackets() error {
+	var iter C.aom_codec_iter_t
+	for {
+		pkt := C.aom_codec_get_cx_data(&e.ctx, &iter)
+		if pkt == nil {
+			return nil
+		}
+		if pkt.kind != C.AOM_CODEC_CX_FRAME_PKT {
+			continue
+		}
+		frame := (*aomFramePkt)(unsafe.Pointer(&pkt.data[0]))
+		data := C.GoBytes(frame.buf, C.int(frame.sz))
+		if err := e.writeIVFFrame(data, int64(frame.pts)); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *av1Encoder) Close() error {
+	// Flush: call encode with a nil image until the codec stops producing
+	// packets, writing each flushed frame packet to the IVF output.
+	for {
+		if C.aom_codec_encode(&e.ctx, nil, 0, 1, 0) != C.AOM_CODEC_OK {
+			break
+		}
+		var iter C.aom_codec_iter_t
+		got := false
+		for pkt := C.aom_codec_get_cx_data(&e.ctx, &iter); pkt != nil; pkt = C.aom_codec_get_cx_data(&e.ctx, &iter) {
+			got = true
+			if pkt.kind == C.AOM_CODEC_CX_FRAME_PKT {
+				frame := (*aomFramePkt)(unsafe.Pointer(&pkt.data[0]))
+				e.writeIVFFrame(C.GoBytes(frame.buf, C.int(frame.sz)), int64(frame.pts))
+			}
+		}
+		if !got {
+			break
+		}
+	}
+
+	C.aom_img_free(e.img)
+	C.aom_codec_destroy(&e.ctx)
+	return e.out.Close()
+}
@@ -0,0 +1,113 @@
+// Package encoder streams rendered frames directly into a video container,
+// replacing the old approach of writing thousands of intermediate PNGs to
+// disk and shelling out to ffmpeg to assemble them afterwards.
+//
+// Workers finish frames out of order, so callers should submit frames
+// through a Sequencer rather than writing to an Encoder directly; the
+// Sequencer buffers early arrivals and feeds the underlying Encoder frames
+// strictly in order.
+package encoder
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+)
+
+// Encoder consumes frames, in order, into a single output file.
+type Encoder interface {
+	WriteFrame(img *image.RGBA) error
+	Close() error
+}
+
+// Backend constructs an Encoder writing to path at the given frame size
+// and rate.
+type Backend func(path string, width, height, fps int) (Encoder, error)
+
+var backends = map[string]Backend{}
+
+func registerBackend(name string, b Backend) {
+	backends[name] = b
+}
+
+// Names lists the backends registered in this build. "mjpeg" is always
+// present; "h264" and "av1" only register when built with cgo and the
+// matching build tag (-tags x264 / -tags av1) against libx264/libaom, so a
+// plain `go build` never fails looking for those dev libraries.
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New constructs the named backend.
+func New(name, path string, width, height, fps int) (Encoder, error) {
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("encoder: unknown or unavailable backend %q (available: %v)", name, Names())
+	}
+	return b(path, width, height, fps)
+}
+
+// Sequencer buffers frames that complete out of order - the worker pool
+// renders frame N and frame N+1 concurrently - and writes them to the
+// underlying Encoder strictly in frame order.
+type Sequencer struct {
+	mu      sync.Mutex
+	next    int
+	pending map[int]*image.RGBA
+	enc     Encoder
+	err     error
+}
+
+// NewSequencer returns a Sequencer that starts draining at startFrame.
+func NewSequencer(enc Encoder, startFrame int) *Sequencer {
+	return &Sequencer{
+		next:    startFrame,
+		pending: make(map[int]*image.RGBA),
+		enc:     enc,
+	}
+}
+
+// Submit delivers a finished frame; it may be written immediately, or
+// buffered until the frames before it arrive.
+func (s *Sequencer) Submit(frame int, img *image.RGBA) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.pending[frame] = img
+	for {
+		next, ok := s.pending[s.next]
+		if !ok {
+			break
+		}
+		if err := s.enc.WriteFrame(next); err != nil {
+			s.err = err
+			return err
+		}
+		delete(s.pending, s.next)
+		s.next++
+	}
+	return nil
+}
+
+// Close flushes the underlying Encoder. Any frames still buffered because
+// an earlier frame never arrived are reported as an error rather than
+// silently dropped.
+func (s *Sequencer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) > 0 && s.err == nil {
+		return fmt.Errorf("encoder: %d frame(s) never completed, starting at frame %d", len(s.pending), s.next)
+	}
+	return s.enc.Close()
+}
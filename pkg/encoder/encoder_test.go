@@ -0,0 +1,98 @@
+package encoder
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+// fakeEncoder records the order frames are written in, and can be made to
+// fail on a chosen frame to exercise Sequencer's error path.
+type fakeEncoder struct {
+	written []int
+	failOn  int // tag to fail on; -1 means never fail
+	closed  bool
+}
+
+func (f *fakeEncoder) WriteFrame(img *image.RGBA) error {
+	n := img.Bounds().Dx() // frames are tagged via their width in these tests
+	if n == f.failOn {
+		return errors.New("boom")
+	}
+	f.written = append(f.written, n)
+	return nil
+}
+
+func (f *fakeEncoder) Close() error {
+	f.closed = true
+	return nil
+}
+
+func frame(tag int) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, tag, 1))
+}
+
+func TestSequencerBuffersOutOfOrderFrames(t *testing.T) {
+	fe := &fakeEncoder{failOn: -1}
+	seq := NewSequencer(fe, 0)
+
+	if err := seq.Submit(2, frame(2)); err != nil {
+		t.Fatalf("Submit(2): %v", err)
+	}
+	if err := seq.Submit(1, frame(1)); err != nil {
+		t.Fatalf("Submit(1): %v", err)
+	}
+	if len(fe.written) != 0 {
+		t.Fatalf("frames 1 and 2 should still be buffered behind frame 0, got %v", fe.written)
+	}
+
+	if err := seq.Submit(0, frame(0)); err != nil {
+		t.Fatalf("Submit(0): %v", err)
+	}
+	if got, want := fe.written, []int{0, 1, 2}; !equal(got, want) {
+		t.Fatalf("written = %v, want %v", got, want)
+	}
+
+	if err := seq.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !fe.closed {
+		t.Fatal("underlying encoder was never closed")
+	}
+}
+
+func TestSequencerCloseReportsMissingFrames(t *testing.T) {
+	fe := &fakeEncoder{failOn: -1}
+	seq := NewSequencer(fe, 0)
+
+	if err := seq.Submit(1, frame(1)); err != nil {
+		t.Fatalf("Submit(1): %v", err)
+	}
+	if err := seq.Close(); err == nil {
+		t.Fatal("Close should report that frame 0 never arrived")
+	}
+}
+
+func TestSequencerStopsAfterEncoderError(t *testing.T) {
+	fe := &fakeEncoder{failOn: 0}
+	seq := NewSequencer(fe, 0)
+
+	if err := seq.Submit(0, frame(0)); err == nil {
+		t.Fatal("Submit should surface the underlying WriteFrame error")
+	}
+	if err := seq.Submit(1, frame(1)); err == nil {
+		t.Fatal("Submit after a failed encode should keep returning the sticky error")
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
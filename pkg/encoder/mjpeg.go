@@ -0,0 +1,254 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+)
+
+func init() {
+	registerBackend("mjpeg", newMJPEGEncoder)
+}
+
+// Fixed byte sizes of the AVI sub-chunks below, in bytes. None of them
+// depend on frame count, so the whole RIFF/hdrl skeleton - including the
+// placeholders WriteFrame's streaming leaves for Close to patch - can be
+// written up front, before a single frame has been encoded.
+const (
+	avihDataSize = 56
+	strhDataSize = 56
+	strfDataSize = 40
+
+	strhChunkSize = 8 + strhDataSize
+	strfChunkSize = 8 + strfDataSize
+	avihChunkSize = 8 + avihDataSize
+
+	strlDataSize  = strhChunkSize + strfChunkSize
+	strlSizeField = 4 + strlDataSize
+	strlTotalSize = 8 + strlSizeField
+
+	hdrlDataSize  = avihChunkSize + strlTotalSize
+	hdrlSizeField = 4 + hdrlDataSize
+)
+
+// idxEntry records one frame's position in the movi data, so Close can
+// write the idx1 chunk without having kept the frame bytes themselves
+// around.
+type idxEntry struct{ offset, size uint32 }
+
+// posWriter tracks the cumulative byte offset written through it, so chunk
+// field offsets (dwTotalFrames, dwLength, the movi LIST size, ...) can be
+// recorded as the header streams out instead of computed from buffer
+// lengths afterwards.
+type posWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (p *posWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+// mjpegEncoder writes a Motion-JPEG stream into an AVI (RIFF) container. It
+// has no external dependencies, so it's the backend available in every
+// build regardless of cgo/codec library availability.
+//
+// Frame sizes aren't known until they're JPEG-encoded, and the total frame
+// count isn't known until Close, so WriteFrame streams each frame straight
+// to disk as it arrives - rather than buffering the whole (possibly
+// thousands-of-frames) sequence in memory - and Close seeks back to patch
+// the handful of header fields (dwTotalFrames, dwLength, the movi and RIFF
+// sizes) that depended on information only available once encoding is
+// done.
+type mjpegEncoder struct {
+	path          string
+	width, height int
+	fps           int
+
+	f   *os.File
+	pos int64
+	idx []idxEntry
+
+	riffSizeOffset    int64
+	totalFramesOffset int64
+	strhLengthOffset  int64
+	moviSizeOffset    int64
+	frameAreaStart    int64
+}
+
+func newMJPEGEncoder(path string, width, height, fps int) (Encoder, error) {
+	e := &mjpegEncoder{path: path, width: width, height: height, fps: fps}
+	if err := e.writeHeader(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// writeHeader opens the output file and writes the RIFF/hdrl/movi headers,
+// with a zero dwTotalFrames/dwLength and a movi size placeholder that Close
+// fills in once the real frame count is known.
+func (e *mjpegEncoder) writeHeader() error {
+	f, err := os.Create(e.path)
+	if err != nil {
+		return err
+	}
+	e.f = f
+	w := &posWriter{w: f}
+
+	w.Write([]byte("RIFF"))
+	e.riffSizeOffset = w.pos
+	w.Write(make([]byte, 4)) // placeholder, patched in Close
+	w.Write([]byte("AVI "))
+
+	w.Write([]byte("LIST"))
+	binary.Write(w, binary.LittleEndian, uint32(hdrlSizeField))
+	w.Write([]byte("hdrl"))
+
+	w.Write([]byte("avih"))
+	binary.Write(w, binary.LittleEndian, uint32(avihDataSize))
+	e.totalFramesOffset = e.writeAvihData(w)
+
+	w.Write([]byte("LIST"))
+	binary.Write(w, binary.LittleEndian, uint32(strlSizeField))
+	w.Write([]byte("strl"))
+
+	w.Write([]byte("strh"))
+	binary.Write(w, binary.LittleEndian, uint32(strhDataSize))
+	e.strhLengthOffset = e.writeStrhData(w)
+
+	w.Write([]byte("strf"))
+	binary.Write(w, binary.LittleEndian, uint32(strfDataSize))
+	e.writeStrfData(w)
+
+	w.Write([]byte("LIST"))
+	e.moviSizeOffset = w.pos
+	w.Write(make([]byte, 4)) // placeholder, patched in Close
+	w.Write([]byte("movi"))
+	e.frameAreaStart = w.pos
+
+	e.pos = w.pos
+	return nil
+}
+
+func (e *mjpegEncoder) writeAvihData(w *posWriter) (totalFramesOffset int64) {
+	usecPerFrame := uint32(1000000 / e.fps)
+	binary.Write(w, binary.LittleEndian, usecPerFrame) // dwMicroSecPerFrame
+	binary.Write(w, binary.LittleEndian, uint32(0))    // dwMaxBytesPerSec
+	binary.Write(w, binary.LittleEndian, uint32(0))    // dwPaddingGranularity
+	binary.Write(w, binary.LittleEndian, uint32(0x10)) // dwFlags: AVIF_HASINDEX
+	totalFramesOffset = w.pos
+	binary.Write(w, binary.LittleEndian, uint32(0)) // dwTotalFrames, patched in Close
+	binary.Write(w, binary.LittleEndian, uint32(0)) // dwInitialFrames
+	binary.Write(w, binary.LittleEndian, uint32(1)) // dwStreams
+	binary.Write(w, binary.LittleEndian, uint32(0)) // dwSuggestedBufferSize
+	binary.Write(w, binary.LittleEndian, uint32(e.width))
+	binary.Write(w, binary.LittleEndian, uint32(e.height))
+	binary.Write(w, binary.LittleEndian, [4]uint32{}) // dwReserved
+	return totalFramesOffset
+}
+
+func (e *mjpegEncoder) writeStrhData(w *posWriter) (lengthOffset int64) {
+	w.Write([]byte("vids"))                             // fccType
+	w.Write([]byte("MJPG"))                             // fccHandler
+	binary.Write(w, binary.LittleEndian, uint32(0))     // dwFlags
+	binary.Write(w, binary.LittleEndian, uint16(0))     // wPriority
+	binary.Write(w, binary.LittleEndian, uint16(0))     // wLanguage
+	binary.Write(w, binary.LittleEndian, uint32(0))     // dwInitialFrames
+	binary.Write(w, binary.LittleEndian, uint32(1))     // dwScale
+	binary.Write(w, binary.LittleEndian, uint32(e.fps)) // dwRate -> rate/scale = fps
+	binary.Write(w, binary.LittleEndian, uint32(0))     // dwStart
+	lengthOffset = w.pos
+	binary.Write(w, binary.LittleEndian, uint32(0))          // dwLength, patched in Close
+	binary.Write(w, binary.LittleEndian, uint32(0))          // dwSuggestedBufferSize
+	binary.Write(w, binary.LittleEndian, uint32(0xFFFFFFFF)) // dwQuality
+	binary.Write(w, binary.LittleEndian, uint32(0))          // dwSampleSize
+	binary.Write(w, binary.LittleEndian, int16(0))           // rcFrame.left
+	binary.Write(w, binary.LittleEndian, int16(0))           // rcFrame.top
+	binary.Write(w, binary.LittleEndian, int16(e.width))     // rcFrame.right
+	binary.Write(w, binary.LittleEndian, int16(e.height))    // rcFrame.bottom
+	return lengthOffset
+}
+
+func (e *mjpegEncoder) writeStrfData(w *posWriter) {
+	binary.Write(w, binary.LittleEndian, uint32(40))                 // biSize
+	binary.Write(w, binary.LittleEndian, int32(e.width))             // biWidth
+	binary.Write(w, binary.LittleEndian, int32(e.height))            // biHeight
+	binary.Write(w, binary.LittleEndian, uint16(1))                  // biPlanes
+	binary.Write(w, binary.LittleEndian, uint16(24))                 // biBitCount
+	w.Write([]byte("MJPG"))                                          // biCompression
+	binary.Write(w, binary.LittleEndian, uint32(e.width*e.height*3)) // biSizeImage
+	binary.Write(w, binary.LittleEndian, int32(0))                   // biXPelsPerMeter
+	binary.Write(w, binary.LittleEndian, int32(0))                   // biYPelsPerMeter
+	binary.Write(w, binary.LittleEndian, uint32(0))                  // biClrUsed
+	binary.Write(w, binary.LittleEndian, uint32(0))                  // biClrImportant
+}
+
+// WriteFrame JPEG-encodes img and appends it to the movi data immediately,
+// recording only its small idx1 entry (offset + size) rather than the
+// frame bytes.
+func (e *mjpegEncoder) WriteFrame(img *image.RGBA) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	e.idx = append(e.idx, idxEntry{offset: uint32(e.pos - e.frameAreaStart), size: uint32(len(data))})
+
+	w := &posWriter{w: e.f, pos: e.pos}
+	writeChunk(w, "00dc", data)
+	e.pos = w.pos
+	return nil
+}
+
+// Close writes the idx1 chunk and seeks back to patch the frame-count and
+// size fields left as placeholders in writeHeader, now that the real frame
+// count and movi size are known.
+func (e *mjpegEncoder) Close() error {
+	moviSize := uint32(4 + (e.pos - e.frameAreaStart)) // "movi" fourCC + frame data
+
+	w := &posWriter{w: e.f, pos: e.pos}
+	var idx1 bytes.Buffer
+	for _, entry := range e.idx {
+		idx1.WriteString("00dc")
+		binary.Write(&idx1, binary.LittleEndian, uint32(0x10)) // AVIIF_KEYFRAME
+		binary.Write(&idx1, binary.LittleEndian, entry.offset)
+		binary.Write(&idx1, binary.LittleEndian, entry.size)
+	}
+	writeChunk(w, "idx1", idx1.Bytes())
+
+	riffSize := uint32(w.pos - 8) // total size minus "RIFF"+size itself
+
+	for _, patch := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{e.moviSizeOffset, moviSize},
+		{e.totalFramesOffset, uint32(len(e.idx))},
+		{e.strhLengthOffset, uint32(len(e.idx))},
+		{e.riffSizeOffset, riffSize},
+	} {
+		if _, err := e.f.Seek(patch.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(e.f, binary.LittleEndian, patch.value); err != nil {
+			return err
+		}
+	}
+
+	return e.f.Close()
+}
+
+func writeChunk(w io.Writer, id string, data []byte) {
+	w.Write([]byte(id))
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.Write([]byte{0}) // RIFF chunks are word-aligned
+	}
+}
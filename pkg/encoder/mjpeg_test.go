@@ -0,0 +1,122 @@
+package encoder
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readChunk reads one RIFF-style "fourCC + uint32 size" header at off and
+// returns the fourCC, the declared size, and the offset of its data.
+func readChunk(t *testing.T, data []byte, off int) (fourCC string, size uint32, dataOff int) {
+	t.Helper()
+	if off+8 > len(data) {
+		t.Fatalf("chunk header at %d runs past end of file (len %d)", off, len(data))
+	}
+	return string(data[off : off+4]), binary.LittleEndian.Uint32(data[off+4 : off+8]), off + 8
+}
+
+// TestMJPEGEncoderWritesWellFormedAVI locks down the byte-level RIFF/AVI
+// framing mjpeg.go streams to disk: chunk/list sizes, the patched
+// dwTotalFrames/dwLength/movi-size/RIFF-size fields, and the idx1 entries,
+// all of which WriteFrame and Close assemble incrementally rather than from
+// a single in-memory buffer.
+func TestMJPEGEncoderWritesWellFormedAVI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.avi")
+
+	enc, err := newMJPEGEncoder(path, 4, 4, 10)
+	if err != nil {
+		t.Fatalf("newMJPEGEncoder: %v", err)
+	}
+
+	const frameCount = 3
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	for i := 0; i < frameCount; i++ {
+		if err := enc.WriteFrame(img); err != nil {
+			t.Fatalf("WriteFrame(%d): %v", i, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	fourCC, riffSize, off := readChunk(t, data, 0)
+	if fourCC != "RIFF" {
+		t.Fatalf("fourCC = %q, want RIFF", fourCC)
+	}
+	if got, want := int(riffSize)+8, len(data); got != want {
+		t.Errorf("RIFF declared size+8 = %d, want actual file size %d", got, want)
+	}
+	if got := string(data[off : off+4]); got != "AVI " {
+		t.Fatalf("RIFF form type = %q, want \"AVI \"", got)
+	}
+	off += 4
+
+	fourCC, hdrlSize, hdrlDataOff := readChunk(t, data, off)
+	if fourCC != "LIST" {
+		t.Fatalf("fourCC = %q, want LIST (hdrl)", fourCC)
+	}
+	if got := string(data[hdrlDataOff : hdrlDataOff+4]); got != "hdrl" {
+		t.Fatalf("LIST type = %q, want hdrl", got)
+	}
+	hdrlEnd := hdrlDataOff + int(hdrlSize) // size field covers the "hdrl" list-type plus contents
+
+	fourCC, avihSize, avihDataOff := readChunk(t, data, hdrlDataOff+4)
+	if fourCC != "avih" || avihSize != avihDataSize {
+		t.Fatalf("first hdrl chunk = (%q, %d), want (avih, %d)", fourCC, avihSize, avihDataSize)
+	}
+	dwTotalFrames := binary.LittleEndian.Uint32(data[avihDataOff+16 : avihDataOff+20])
+	if dwTotalFrames != frameCount {
+		t.Errorf("dwTotalFrames = %d, want %d", dwTotalFrames, frameCount)
+	}
+
+	fourCC, moviSize, moviDataOff := readChunk(t, data, hdrlEnd)
+	if fourCC != "LIST" {
+		t.Fatalf("fourCC after hdrl = %q, want LIST (movi)", fourCC)
+	}
+	if got := string(data[moviDataOff : moviDataOff+4]); got != "movi" {
+		t.Fatalf("LIST type = %q, want movi", got)
+	}
+	frameAreaStart := moviDataOff + 4
+	moviEnd := moviDataOff + int(moviSize)
+
+	var frames int
+	for p := frameAreaStart; p < moviEnd; {
+		fourCC, size, dataOff := readChunk(t, data, p)
+		if fourCC != "00dc" {
+			t.Fatalf("movi entry at %d has fourCC %q, want 00dc", p, fourCC)
+		}
+		p = dataOff + int(size)
+		if size%2 == 1 {
+			p++ // word-aligned padding
+		}
+		frames++
+	}
+	if frames != frameCount {
+		t.Errorf("movi contains %d frame chunks, want %d", frames, frameCount)
+	}
+
+	fourCC, idx1Size, idx1DataOff := readChunk(t, data, moviEnd)
+	if fourCC != "idx1" {
+		t.Fatalf("fourCC after movi = %q, want idx1", fourCC)
+	}
+	if got, want := int(idx1Size)/16, frameCount; got != want {
+		t.Errorf("idx1 has %d entries (size %d), want %d", got, idx1Size, want)
+	}
+	if idx1DataOff+int(idx1Size) != len(data) {
+		t.Errorf("idx1 chunk doesn't reach end of file: ends at %d, file len %d", idx1DataOff+int(idx1Size), len(data))
+	}
+}
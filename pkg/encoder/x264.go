@@ -0,0 +1,138 @@
+//go:build cgo && x264
+
+package encoder
+
+/*
+#cgo pkg-config: x264
+#include <stdlib.h>
+#include <x264.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"unsafe"
+)
+
+func init() {
+	registerBackend("h264", newX264Encoder)
+}
+
+// x264Encoder encodes frames to H.264 via libx264, writing raw Annex-B
+// access units to path. Frames are converted from RGBA to I420 (the only
+// chroma subsampling x264 accepts for 8-bit input) before encoding.
+type x264Encoder struct {
+	out           *os.File
+	enc           *C.x264_t
+	pic           *C.x264_picture_t
+	width, height int
+	frameIndex    C.int64_t
+}
+
+func newX264Encoder(path string, width, height, fps int) (Encoder, error) {
+	var param C.x264_param_t
+	if C.x264_param_default_preset(&param, C.CString("medium"), nil) < 0 {
+		return nil, fmt.Errorf("x264: unknown preset")
+	}
+	param.i_width = C.int(width)
+	param.i_height = C.int(height)
+	param.i_fps_num = C.uint32_t(fps)
+	param.i_fps_den = 1
+	param.i_csp = C.X264_CSP_I420
+	C.x264_param_apply_profile(&param, C.CString("high"))
+
+	enc := C.x264_encoder_open(&param)
+	if enc == nil {
+		return nil, fmt.Errorf("x264: failed to open encoder")
+	}
+
+	pic := &C.x264_picture_t{}
+	if C.x264_picture_alloc(pic, C.X264_CSP_I420, C.int(width), C.int(height)) < 0 {
+		C.x264_encoder_close(enc)
+		return nil, fmt.Errorf("x264: failed to allocate picture")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		C.x264_picture_clean(pic)
+		C.x264_encoder_close(enc)
+		return nil, err
+	}
+
+	return &x264Encoder{out: f, enc: enc, pic: pic, width: width, height: height}, nil
+}
+
+func rgbaToI420(img *image.RGBA, pic *C.x264_picture_t, width, height int) {
+	yPlane := unsafe.Slice((*C.uint8_t)(pic.img.plane[0]), int(pic.img.i_stride[0])*height)
+	uPlane := unsafe.Slice((*C.uint8_t)(pic.img.plane[1]), int(pic.img.i_stride[1])*height/2)
+	vPlane := unsafe.Slice((*C.uint8_t)(pic.img.plane[2]), int(pic.img.i_stride[2])*height/2)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px := img.RGBAAt(x, y)
+			yy := 0.299*float64(px.R) + 0.587*float64(px.G) + 0.114*float64(px.B)
+			yPlane[y*int(pic.img.i_stride[0])+x] = C.uint8_t(clamp255(yy))
+
+			if x%2 == 0 && y%2 == 0 {
+				u := -0.169*float64(px.R) - 0.331*float64(px.G) + 0.5*float64(px.B) + 128
+				v := 0.5*float64(px.R) - 0.419*float64(px.G) - 0.081*float64(px.B) + 128
+				uPlane[(y/2)*int(pic.img.i_stride[1])+x/2] = C.uint8_t(clamp255(u))
+				vPlane[(y/2)*int(pic.img.i_stride[2])+x/2] = C.uint8_t(clamp255(v))
+			}
+		}
+	}
+}
+
+func clamp255(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+func (e *x264Encoder) WriteFrame(img *image.RGBA) error {
+	rgbaToI420(img, e.pic, e.width, e.height)
+	e.pic.i_pts = e.frameIndex
+	e.frameIndex++
+
+	var nals *C.x264_nal_t
+	var nalCount C.int
+	var outPic C.x264_picture_t
+
+	size := C.x264_encoder_encode(e.enc, &nals, &nalCount, e.pic, &outPic)
+	if size < 0 {
+		return fmt.Errorf("x264: encode failed")
+	}
+	if size > 0 {
+		buf := C.GoBytes(unsafe.Pointer(nals.p_payload), size)
+		_, err := e.out.Write(buf)
+		return err
+	}
+	return nil
+}
+
+func (e *x264Encoder) Close() error {
+	// Flush any frames libx264 is still holding for lookahead/B-frame reordering.
+	for C.x264_encoder_delayed_frames(e.enc) > 0 {
+		var nals *C.x264_nal_t
+		var nalCount C.int
+		var outPic C.x264_picture_t
+		size := C.x264_encoder_encode(e.enc, &nals, &nalCount, nil, &outPic)
+		if size < 0 {
+			break
+		}
+		if size > 0 {
+			buf := C.GoBytes(unsafe.Pointer(nals.p_payload), size)
+			e.out.Write(buf)
+		}
+	}
+
+	C.x264_picture_clean(e.pic)
+	C.x264_encoder_close(e.enc)
+	return e.out.Close()
+}
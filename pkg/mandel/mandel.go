@@ -0,0 +1,40 @@
+// Package mandel implements the core Mandelbrot recurrence and its
+// escape-region shortcuts once, shared by the batch renderer, the tile
+// server, and the SIMD scalar reference - previously each kept its own
+// copy of both.
+package mandel
+
+// InMainCardioidOrBulb reports whether c = x+yi lies in the main cardioid
+// or the period-2 bulb, the two largest regions of the set, letting callers
+// skip the iteration loop entirely for points that are in-set by
+// construction.
+func InMainCardioidOrBulb(x, y float64) bool {
+	q := (x-0.25)*(x-0.25) + y*y
+	if q*(q+(x-0.25)) < 0.25*y*y {
+		return true // main cardioid
+	}
+	return (x+1)*(x+1)+y*y < 1.0/16.0 // period-2 bulb
+}
+
+// Iterate runs z_{n+1} = z_n^2 + c from z_0 = c, stopping at the first n
+// where |z_n| > 2 (escaped) or at maxIter (never escaped so far as this
+// test can tell). It returns the escape iteration and the z value at that
+// iteration: callers that want smooth coloring renormalize n and z
+// themselves (e.g. via palette.SmoothIterCount); callers that only need the
+// raw count, like the SIMD correctness reference, can ignore z.
+func Iterate(cRe, cIm float64, maxIter int) (n int, z complex128) {
+	if InMainCardioidOrBulb(cRe, cIm) {
+		return maxIter, complex(cRe, cIm)
+	}
+
+	c := complex(cRe, cIm)
+	z = c
+	for ; n < maxIter; n++ {
+		zr, zi := real(z), imag(z)
+		if zr*zr+zi*zi > 4 {
+			return n, z
+		}
+		z = z*z + c
+	}
+	return maxIter, z
+}
@@ -0,0 +1,182 @@
+// Package palette replaces the renderer's hardcoded blue gradient with
+// named, interpolated color palettes, plus the renormalized escape-count
+// math needed to color them without banding.
+package palette
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"os"
+	"sort"
+)
+
+// Stop is one control point of a palette: at Pos (in [0,1] of the iteration
+// ratio) the palette takes on Color, interpolating linearly between stops.
+type Stop struct {
+	Pos   float64
+	Color color.RGBA
+}
+
+// Palette interpolates between a small set of RGB stops across the
+// [0,1] iteration-ratio range.
+type Palette struct {
+	Name  string
+	Stops []Stop
+}
+
+// Lookup returns the interpolated color for iterRatio, a value in [0,1]
+// where 1 means the pixel never escaped (in the set).
+func (p *Palette) Lookup(iterRatio float64) color.RGBA {
+	if iterRatio <= p.Stops[0].Pos {
+		return p.Stops[0].Color
+	}
+	last := len(p.Stops) - 1
+	if iterRatio >= p.Stops[last].Pos {
+		return p.Stops[last].Color
+	}
+
+	for i := 0; i < last; i++ {
+		a, b := p.Stops[i], p.Stops[i+1]
+		if iterRatio >= a.Pos && iterRatio <= b.Pos {
+			t := (iterRatio - a.Pos) / (b.Pos - a.Pos)
+			return color.RGBA{
+				R: lerp(a.Color.R, b.Color.R, t),
+				G: lerp(a.Color.G, b.Color.G, t),
+				B: lerp(a.Color.B, b.Color.B, t),
+				A: 255,
+			}
+		}
+	}
+	return p.Stops[last].Color
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// New builds a palette from caller-provided stops, sorting them by Pos.
+func New(name string, stops []Stop) *Palette {
+	sorted := append([]Stop(nil), stops...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Pos < sorted[j].Pos })
+	return &Palette{Name: name, Stops: sorted}
+}
+
+// SmoothIterCount computes the standard renormalized escape count
+// mu = n + 1 - log(log(|z|))/log(2) for a pixel that escaped on iteration
+// n with final value z, giving continuous (banding-free) coloring in
+// place of the raw integer iteration count.
+func SmoothIterCount(n int, z complex128) float64 {
+	return float64(n) + 1 - math.Log(math.Log(cmplx.Abs(z)))/math.Log(2)
+}
+
+type jsonPalette struct {
+	Name  string `json:"name"`
+	Stops []struct {
+		Pos   float64  `json:"pos"`
+		Color [3]uint8 `json:"color"`
+	} `json:"stops"`
+}
+
+// Load reads a palette from a JSON file of the form:
+//
+//	{"name": "custom", "stops": [{"pos": 0, "color": [0,0,0]}, ...]}
+func Load(path string) (*Palette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("palette: %w", err)
+	}
+	var jp jsonPalette
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return nil, fmt.Errorf("palette: %w", err)
+	}
+	stops := make([]Stop, len(jp.Stops))
+	for i, s := range jp.Stops {
+		stops[i] = Stop{Pos: s.Pos, Color: color.RGBA{R: s.Color[0], G: s.Color[1], B: s.Color[2], A: 255}}
+	}
+	return New(jp.Name, stops), nil
+}
+
+var registry = map[string]*Palette{}
+
+func register(p *Palette) {
+	registry[p.Name] = p
+}
+
+// Get returns the named built-in palette, if any.
+func Get(name string) (*Palette, bool) {
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names lists the built-in palette names.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func rgb(r, g, b uint8) color.RGBA { return color.RGBA{r, g, b, 255} }
+
+func init() {
+	register(New("hippi", []Stop{
+		{0.0, rgb(0, 7, 100)},
+		{0.16, rgb(32, 107, 203)},
+		{0.42, rgb(237, 255, 255)},
+		{0.6425, rgb(255, 170, 0)},
+		{0.8575, rgb(0, 2, 0)},
+		{1.0, rgb(0, 0, 0)},
+	}))
+
+	register(New("plan9", []Stop{
+		{0.0, rgb(47, 30, 60)},
+		{0.5, rgb(140, 90, 180)},
+		{1.0, rgb(20, 10, 30)},
+	}))
+
+	register(New("afternoon-blue", []Stop{
+		{0.0, rgb(5, 15, 45)},
+		{0.5, rgb(60, 130, 220)},
+		{1.0, rgb(5, 15, 45)},
+	}))
+
+	register(New("fiesta", []Stop{
+		{0.0, rgb(60, 0, 0)},
+		{0.25, rgb(220, 30, 30)},
+		{0.5, rgb(250, 180, 20)},
+		{0.75, rgb(30, 160, 60)},
+		{1.0, rgb(10, 10, 10)},
+	}))
+
+	register(New("viridis", []Stop{
+		{0.0, rgb(68, 1, 84)},
+		{0.25, rgb(59, 82, 139)},
+		{0.5, rgb(33, 145, 140)},
+		{0.75, rgb(94, 201, 98)},
+		{1.0, rgb(253, 231, 37)},
+	}))
+
+	register(New("magma", []Stop{
+		{0.0, rgb(0, 0, 4)},
+		{0.25, rgb(81, 18, 124)},
+		{0.5, rgb(183, 55, 121)},
+		{0.75, rgb(252, 137, 97)},
+		{1.0, rgb(252, 253, 191)},
+	}))
+
+	register(New("grayscale", []Stop{
+		{0.0, rgb(0, 0, 0)},
+		{1.0, rgb(255, 255, 255)},
+	}))
+
+	register(New("binary", []Stop{
+		{0.0, rgb(255, 255, 255)},
+		{0.999, rgb(255, 255, 255)},
+		{1.0, rgb(0, 0, 0)},
+	}))
+}
@@ -0,0 +1,49 @@
+package palette
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPaletteLookupInterpolatesBetweenStops(t *testing.T) {
+	p := New("test", []Stop{
+		{Pos: 0.0, Color: color.RGBA{R: 0, G: 0, B: 0, A: 255}},
+		{Pos: 1.0, Color: color.RGBA{R: 200, G: 100, B: 50, A: 255}},
+	})
+
+	if got := p.Lookup(0.5); got != (color.RGBA{R: 100, G: 50, B: 25, A: 255}) {
+		t.Errorf("Lookup(0.5) = %+v, want midpoint color", got)
+	}
+	if got := p.Lookup(-1); got != (color.RGBA{R: 0, G: 0, B: 0, A: 255}) {
+		t.Errorf("Lookup(-1) = %+v, want first stop clamped", got)
+	}
+	if got := p.Lookup(2); got != (color.RGBA{R: 200, G: 100, B: 50, A: 255}) {
+		t.Errorf("Lookup(2) = %+v, want last stop clamped", got)
+	}
+}
+
+func TestNewSortsStopsByPos(t *testing.T) {
+	p := New("test", []Stop{
+		{Pos: 1.0, Color: color.RGBA{A: 255}},
+		{Pos: 0.0, Color: color.RGBA{R: 255, A: 255}},
+	})
+	if p.Stops[0].Pos != 0.0 || p.Stops[1].Pos != 1.0 {
+		t.Errorf("Stops not sorted by Pos: %+v", p.Stops)
+	}
+}
+
+func TestBuiltinPalettesRegistered(t *testing.T) {
+	for _, name := range []string{"hippi", "plan9", "viridis", "grayscale"} {
+		if _, ok := Get(name); !ok {
+			t.Errorf("built-in palette %q not registered", name)
+		}
+	}
+}
+
+func TestSmoothIterCountIncreasesWithIterations(t *testing.T) {
+	lo := SmoothIterCount(5, complex(3, 0))
+	hi := SmoothIterCount(50, complex(3, 0))
+	if hi <= lo {
+		t.Errorf("SmoothIterCount(50, ...) = %f, want > SmoothIterCount(5, ...) = %f", hi, lo)
+	}
+}
@@ -0,0 +1,71 @@
+// Package progressive renders an image in coarse-to-fine passes - an 8x8
+// subsampled preview first, refining through 4x4, 2x2, down to a full 1x1
+// pass - so a caller watching a deep-zoom frame render sees something
+// appear quickly instead of waiting on the whole frame. Each pass checks
+// the caller's context before each row of blocks, so a frame can be aborted mid-pass
+// (e.g. the viewer panned away from this tile).
+package progressive
+
+import (
+	"context"
+	"image"
+
+	"rustlebrot/pkg/palette"
+)
+
+// Passes are the subsample block sizes rendered in order.
+var Passes = []int{8, 4, 2, 1}
+
+// PixelFunc computes the iteration ratio in [0,1] for one point of the
+// complex plane.
+type PixelFunc func(c complex128) float64
+
+// Render fills img by running each pass in Passes over the region
+// [xRange,yRange]: every block-sized square is sampled once at its
+// top-left corner and flooded with that color, then the next (smaller)
+// pass resamples and overwrites at finer granularity. onPass, if non-nil,
+// is called after each completed pass with its block size, so a caller
+// can push the intermediate frame somewhere (a PNG, a tile-server
+// response) before refining further.
+//
+// Render returns ctx.Err() if the context is canceled before a row of blocks,
+// leaving img partially refined at whatever pass was in progress.
+func Render(ctx context.Context, img *image.RGBA, xRange, yRange [2]float64, pal *palette.Palette, pixel PixelFunc, onPass func(block int)) error {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	scalex := (xRange[1] - xRange[0]) / float64(width)
+	scaley := (yRange[1] - yRange[0]) / float64(height)
+
+	for _, block := range Passes {
+		for by := 0; by < height; by += block {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			y1 := by + block
+			if y1 > height {
+				y1 = height
+			}
+			for bx := 0; bx < width; bx += block {
+				x1 := bx + block
+				if x1 > width {
+					x1 = width
+				}
+
+				c := complex(float64(bx)*scalex+xRange[0], float64(by)*scaley+yRange[0])
+				col := pal.Lookup(pixel(c))
+
+				for y := by; y < y1; y++ {
+					for x := bx; x < x1; x++ {
+						img.Set(x, y, col)
+					}
+				}
+			}
+		}
+		if onPass != nil {
+			onPass(block)
+		}
+	}
+	return nil
+}
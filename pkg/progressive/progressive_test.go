@@ -0,0 +1,85 @@
+package progressive
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"rustlebrot/pkg/palette"
+)
+
+var (
+	black = color.RGBA{A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+func testPalette() *palette.Palette {
+	return palette.New("test", []palette.Stop{
+		{Pos: 0.0, Color: black},
+		{Pos: 1.0, Color: white},
+	})
+}
+
+func TestRenderProducesFinalImageMatchingPixelFunc(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	pal := testPalette()
+
+	// Left half reports 0 (black), right half reports 1 (white); the last
+	// (1x1) pass should sample every pixel individually and land on its own
+	// half's color regardless of what earlier coarse passes painted there.
+	pixel := func(c complex128) float64 {
+		if real(c) < 0.5 {
+			return 0
+		}
+		return 1
+	}
+
+	if err := Render(context.Background(), img, [2]float64{0, 1}, [2]float64{0, 1}, pal, pixel, nil); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	if got := img.RGBAAt(0, 0); got != black {
+		t.Errorf("pixel (0,0) = %+v, want black", got)
+	}
+	if got := img.RGBAAt(7, 7); got != white {
+		t.Errorf("pixel (7,7) = %+v, want white", got)
+	}
+}
+
+func TestRenderCallsOnPassForEveryPassInOrder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	pal := testPalette()
+
+	var seen []int
+	pixel := func(c complex128) float64 { return 0 }
+
+	if err := Render(context.Background(), img, [2]float64{0, 1}, [2]float64{0, 1}, pal, pixel, func(block int) {
+		seen = append(seen, block)
+	}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	if len(seen) != len(Passes) {
+		t.Fatalf("onPass called %d times, want %d", len(seen), len(Passes))
+	}
+	for i, block := range Passes {
+		if seen[i] != block {
+			t.Errorf("onPass[%d] = %d, want %d", i, seen[i], block)
+		}
+	}
+}
+
+func TestRenderReturnsCtxErrOnCancellation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	pal := testPalette()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Render(ctx, img, [2]float64{0, 1}, [2]float64{0, 1}, pal, func(c complex128) float64 { return 0 }, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Render with a pre-canceled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package scheduler decomposes a batch of frames into row-granularity jobs
+// and drains them with a worker pool that pulls across all in-flight
+// frames, instead of handing each worker a whole frame. One-frame-per-
+// worker wastes cores whenever frame count < NumCPU, and load-imbalances
+// near the set boundary where some rows iterate to maxIter while others
+// escape in a handful of steps.
+package scheduler
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RowJob is one unit of work: render rows [Y, Y+Height) of the image for
+// Frame.
+type RowJob struct {
+	Frame  int
+	Y      int
+	Height int
+}
+
+// RowRenderer renders the rows described by job and reports how many
+// pixels it filled and the total iteration count across them, so the
+// scheduler can both adapt future batch sizes and report throughput.
+type RowRenderer func(job RowJob) (pixels int, iterations int64)
+
+// Stats aggregates throughput counters across a Scheduler run.
+type Stats struct {
+	Pixels     int64
+	Iterations int64
+	Elapsed    time.Duration
+}
+
+// PixelsPerSec is the aggregate render throughput.
+func (s Stats) PixelsPerSec() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Pixels) / s.Elapsed.Seconds()
+}
+
+// AvgIterPerPixel is the mean iteration count spent per rendered pixel.
+func (s Stats) AvgIterPerPixel() float64 {
+	if s.Pixels == 0 {
+		return 0
+	}
+	return float64(s.Iterations) / float64(s.Pixels)
+}
+
+// Scheduler renders row batches with Workers goroutines, shrinking batch
+// height as rows get denser (more iterations per pixel, i.e. closer to the
+// set boundary) and growing it in the cheap escape region, where more rows
+// per job means less channel/scheduling overhead.
+type Scheduler struct {
+	Workers         int
+	MinBatch        int
+	MaxBatch        int
+	maxIterPerPixel float64 // upper bound used to normalize the adaptive density signal
+	avgDensityBits  uint64  // atomic: math.Float64bits of an EMA in [0,1], 0 means "no data yet"
+}
+
+// New returns a Scheduler with workers goroutines pulling row jobs of
+// between minBatch and maxBatch rows. maxIterPerPixel should be the
+// maxIter used by the renderer, so observed iteration density can be
+// normalized into [0,1] for the batch-size feedback loop.
+func New(workers, minBatch, maxBatch, maxIterPerPixel int) *Scheduler {
+	return &Scheduler{
+		Workers:         workers,
+		MinBatch:        minBatch,
+		MaxBatch:        maxBatch,
+		maxIterPerPixel: float64(maxIterPerPixel),
+	}
+}
+
+func (s *Scheduler) observedDensity() float64 {
+	bits := atomic.LoadUint64(&s.avgDensityBits)
+	if bits == 0 {
+		return 0
+	}
+	return math.Float64frombits(bits)
+}
+
+// recordDensity folds a batch's observed iterations-per-pixel (normalized
+// to [0,1]) into the running average via exponential smoothing.
+func (s *Scheduler) recordDensity(sample float64) {
+	const smoothing = 0.2
+	for {
+		prevBits := atomic.LoadUint64(&s.avgDensityBits)
+		prev := math.Float64frombits(prevBits)
+		if prevBits == 0 {
+			prev = sample
+		}
+		next := prev + smoothing*(sample-prev)
+		if atomic.CompareAndSwapUint64(&s.avgDensityBits, prevBits, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// nextBatchHeight picks the next row-batch size: dense regions (most
+// pixels running to maxIter, near the set boundary) get the smallest
+// batches so no worker is stuck on one long job while others idle; sparse,
+// fast-escaping regions get the largest batches to amortize scheduling
+// overhead.
+func (s *Scheduler) nextBatchHeight() int {
+	density := s.observedDensity()
+	span := float64(s.MaxBatch - s.MinBatch)
+	height := float64(s.MaxBatch) - density*span
+	if height < float64(s.MinBatch) {
+		height = float64(s.MinBatch)
+	}
+	return int(height)
+}
+
+// Run decomposes each frame (0..height rows wide) into row jobs and drains
+// them with the worker pool until every row of every frame is rendered,
+// then returns aggregate throughput stats.
+func (s *Scheduler) Run(frames []int, height int, render RowRenderer) Stats {
+	jobs := make(chan RowJob, s.Workers*4)
+
+	go func() {
+		defer close(jobs)
+		for _, frame := range frames {
+			for y := 0; y < height; {
+				batch := s.nextBatchHeight()
+				if y+batch > height {
+					batch = height - y
+				}
+				jobs <- RowJob{Frame: frame, Y: y, Height: batch}
+				y += batch
+			}
+		}
+	}()
+
+	var pixels, iterations int64
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for w := 0; w < s.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				p, iter := render(job)
+				atomic.AddInt64(&pixels, int64(p))
+				atomic.AddInt64(&iterations, iter)
+				if p > 0 && s.maxIterPerPixel > 0 {
+					s.recordDensity(float64(iter) / float64(p) / s.maxIterPerPixel)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return Stats{Pixels: pixels, Iterations: iterations, Elapsed: time.Since(start)}
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNextBatchHeightShrinksAsDensityRises(t *testing.T) {
+	s := New(1, 8, 128, 1000)
+
+	if got := s.nextBatchHeight(); got != s.MaxBatch {
+		t.Fatalf("with no samples yet, nextBatchHeight() = %d, want MaxBatch %d", got, s.MaxBatch)
+	}
+
+	s.recordDensity(1.0) // every pixel ran to maxIter: the densest possible batch
+	if got := s.nextBatchHeight(); got != s.MinBatch {
+		t.Fatalf("after a fully-dense sample, nextBatchHeight() = %d, want MinBatch %d", got, s.MinBatch)
+	}
+}
+
+func TestRecordDensitySmoothsTowardNewSamples(t *testing.T) {
+	s := New(1, 8, 128, 1000)
+
+	s.recordDensity(1.0)
+	if got := s.observedDensity(); got != 1.0 {
+		t.Fatalf("first sample should seed the EMA directly, observedDensity() = %f, want 1.0", got)
+	}
+
+	s.recordDensity(0.0)
+	got := s.observedDensity()
+	if got <= 0 || got >= 1.0 {
+		t.Fatalf("observedDensity() after smoothing toward 0 = %f, want strictly between 0 and 1", got)
+	}
+}
+
+func TestRunAggregatesAcrossFramesAndWorkers(t *testing.T) {
+	s := New(4, 2, 4, 100)
+	frames := []int{0, 1, 2}
+	const height = 10
+
+	seen := make(map[int][]RowJob)
+	var mu sync.Mutex
+	stats := s.Run(frames, height, func(job RowJob) (int, int64) {
+		mu.Lock()
+		seen[job.Frame] = append(seen[job.Frame], job)
+		mu.Unlock()
+		return job.Height, int64(job.Height * 50)
+	})
+
+	if stats.Pixels != int64(len(frames)*height) {
+		t.Errorf("Stats.Pixels = %d, want %d", stats.Pixels, len(frames)*height)
+	}
+
+	for _, frame := range frames {
+		var rows int
+		for _, job := range seen[frame] {
+			rows += job.Height
+		}
+		if rows != height {
+			t.Errorf("frame %d: jobs covered %d rows, want %d", frame, rows, height)
+		}
+	}
+}
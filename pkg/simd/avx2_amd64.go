@@ -0,0 +1,46 @@
+//go:build amd64
+
+package simd
+
+// step4AVX2 advances 4 lanes of z_{n+1} = z_n^2 + c by one iteration:
+// zr/zi are updated in place to the next iterate, and mag2Out receives
+// |z_n|^2 (computed from the *pre-update* zr/zi) for the caller's bailout
+// check. Splitting the vectorized math out into this single step, with
+// the escape bookkeeping left to Go, keeps the assembly to plain
+// register-to-register AVX2 ops and no constant pool.
+//
+//go:noescape
+func step4AVX2(zr, zi, cRe, cIm, mag2Out *[4]float64)
+
+// iterate4AVX2 is the AVX2-backed Iterate4 kernel: it drives step4AVX2
+// for up to maxIter steps, recording each lane's first-bailout iteration
+// into out, or maxIter if a lane never escapes.
+func iterate4AVX2(cRe, cIm *[4]float64, maxIter int32, out *[4]int32) {
+	zr, zi := *cRe, *cIm
+	var mag2 [4]float64
+	var recorded [4]bool
+
+	for i := range out {
+		out[i] = maxIter
+	}
+
+	for n := int32(0); n < maxIter; n++ {
+		step4AVX2(&zr, &zi, cRe, cIm, &mag2)
+
+		allRecorded := true
+		for i := 0; i < Lanes; i++ {
+			if recorded[i] {
+				continue
+			}
+			if mag2[i] > 4 {
+				out[i] = n
+				recorded[i] = true
+			} else {
+				allRecorded = false
+			}
+		}
+		if allRecorded {
+			return
+		}
+	}
+}
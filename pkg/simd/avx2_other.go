@@ -0,0 +1,11 @@
+//go:build !amd64
+
+package simd
+
+// iterate4AVX2 only exists so Iterate4 compiles on every architecture;
+// HasAVX2 is always false here, so this is never actually reached.
+func iterate4AVX2(cRe, cIm *[4]float64, maxIter int32, out *[4]int32) {
+	for i := 0; i < Lanes; i++ {
+		out[i] = int32(IterateScalar(cRe[i], cIm[i], int(maxIter)))
+	}
+}
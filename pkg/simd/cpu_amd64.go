@@ -0,0 +1,14 @@
+//go:build amd64
+
+package simd
+
+// cpuid wraps the CPUID instruction for the given leaf/subleaf.
+func cpuid(eaxIn, ecxIn uint32) (eax, ebx, ecx, edx uint32)
+
+// detectAVX2 checks CPUID leaf 7, sub-leaf 0, EBX bit 5 - the standard
+// "extended features" AVX2-support flag.
+func detectAVX2() bool {
+	_, ebx, _, _ := cpuid(7, 0)
+	const avx2Bit = 1 << 5
+	return ebx&avx2Bit != 0
+}
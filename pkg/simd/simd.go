@@ -0,0 +1,72 @@
+// Package simd vectorizes the Mandelbrot inner loop, iterating 4 complex
+// points per step on amd64/AVX2 instead of one. The scalar path is always
+// available - it's the reference implementation used on arm64 and to
+// check the vector kernel for correctness - and callers select a backend
+// with the -simd flag ("auto", "off", "avx2", "avx512"); the per-pixel
+// iteration count returned is identical either way, so the palette code
+// upstream doesn't care which backend ran.
+package simd
+
+import "rustlebrot/pkg/mandel"
+
+// Backend names accepted by the -simd flag.
+const (
+	Auto   = "auto"
+	Off    = "off"
+	AVX2   = "avx2"
+	AVX512 = "avx512" // accepted but not yet implemented; resolves to Off
+)
+
+// Lanes is how many points a single Iterate4 call processes.
+const Lanes = 4
+
+// HasAVX2 reports whether this build and the running CPU support the AVX2
+// backend. It is always false on non-amd64 builds.
+var HasAVX2 = detectAVX2()
+
+// Select resolves a -simd flag value to the backend that will actually
+// run, given what this build and CPU support.
+func Select(flagValue string) string {
+	switch flagValue {
+	case Off:
+		return Off
+	case AVX2:
+		if !HasAVX2 {
+			return Off
+		}
+		return AVX2
+	case AVX512:
+		return Off
+	default: // Auto and anything unrecognized
+		if HasAVX2 {
+			return AVX2
+		}
+		return Off
+	}
+}
+
+// IterateScalar iterates z_{n+1} = z_n^2 + c for one point and returns the
+// escape iteration (Lanes-wide callers fall back to this per-lane when no
+// vector backend is selected, and it doubles as the AVX2 kernel's
+// correctness reference).
+func IterateScalar(cRe, cIm float64, maxIter int) int {
+	n, _ := mandel.Iterate(cRe, cIm, maxIter)
+	return n
+}
+
+// Iterate4 runs the Mandelbrot iteration for Lanes points at once,
+// dispatching to the backend named by backend (the resolved value from
+// Select) or the scalar reference if that backend isn't available.
+func Iterate4(backend string, cRe, cIm [4]float64, maxIter int) [4]int {
+	if backend == AVX2 && HasAVX2 {
+		var out [4]int32
+		iterate4AVX2(&cRe, &cIm, int32(maxIter), &out)
+		return [4]int{int(out[0]), int(out[1]), int(out[2]), int(out[3])}
+	}
+
+	var out [4]int
+	for i := 0; i < Lanes; i++ {
+		out[i] = IterateScalar(cRe[i], cIm[i], maxIter)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package simd
+
+import "testing"
+
+// deepInSet is worst-case for both backends: every lane runs the full
+// maxIter without ever escaping. The points are real-axis centers of
+// period-3/4/8 components, outside the main cardioid and period-2 bulb, so
+// the scalar path's early-out does not skip them.
+var deepInSet = [4]float64{-1.3107026413368328, -1.7548776662466927, -1.9407998065294847, -1.3815474844320617}
+
+// mixedBoundary has lanes that escape quickly alongside ones near the set
+// boundary, to catch bugs in the per-lane early-exit bookkeeping.
+var mixedBoundary = [4]float64{2.0, -1.0, -0.75, 0.25}
+var mixedBoundaryIm = [4]float64{0, 0, 0.1, 0}
+
+func TestIterate4MatchesScalar(t *testing.T) {
+	if !HasAVX2 {
+		t.Skip("AVX2 not available on this build/CPU")
+	}
+
+	const maxIter = 1000
+	cases := []struct {
+		name     string
+		cRe, cIm [4]float64
+	}{
+		{"deep-in-set", deepInSet, [4]float64{0, 0, 0, 0}},
+		{"mixed-boundary", mixedBoundary, mixedBoundaryIm},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Iterate4(AVX2, c.cRe, c.cIm, maxIter)
+			for i := 0; i < Lanes; i++ {
+				want := IterateScalar(c.cRe[i], c.cIm[i], maxIter)
+				if got[i] != want {
+					t.Errorf("lane %d: AVX2=%d scalar=%d", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScalarDeepInSet(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for lane := 0; lane < Lanes; lane++ {
+			IterateScalar(deepInSet[lane], 0, 1000)
+		}
+	}
+}
+
+func BenchmarkAVX2DeepInSet(b *testing.B) {
+	if !HasAVX2 {
+		b.Skip("AVX2 not available on this build/CPU")
+	}
+	cIm := [4]float64{0, 0, 0, 0}
+	for i := 0; i < b.N; i++ {
+		Iterate4(AVX2, deepInSet, cIm, 1000)
+	}
+}
+
+func BenchmarkScalarMixedBoundary(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for lane := 0; lane < Lanes; lane++ {
+			IterateScalar(mixedBoundary[lane], mixedBoundaryIm[lane], 1000)
+		}
+	}
+}
+
+func BenchmarkAVX2MixedBoundary(b *testing.B) {
+	if !HasAVX2 {
+		b.Skip("AVX2 not available on this build/CPU")
+	}
+	for i := 0; i < b.N; i++ {
+		Iterate4(AVX2, mixedBoundary, mixedBoundaryIm, 1000)
+	}
+}
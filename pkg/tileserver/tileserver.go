@@ -0,0 +1,252 @@
+// Package tileserver exposes the Mandelbrot renderer over HTTP using the
+// slippy-map tile convention (/tile/{z}/{x}/{y}.png), so the set can be
+// explored live in any Leaflet-compatible viewer instead of only through
+// pre-rendered zoom sequences.
+package tileserver
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"rustlebrot/pkg/mandel"
+	"rustlebrot/pkg/palette"
+	"rustlebrot/pkg/progressive"
+)
+
+const tileSize = 256
+
+// worldRe0, worldIm0 and worldSize give the complex-plane bounds of the
+// single tile at zoom level 0: the [-2,2]x[-2,2] square, as wide as the
+// batch renderer's view at zoom 1 (which is centered on its deep-zoom point
+// rather than on 0).
+const (
+	worldRe0  = -2.0
+	worldIm0  = -2.0
+	worldSize = 4.0
+)
+
+// TileJob describes one tile to render: its slippy-map coordinates, the
+// rendering parameters that affect its pixels, and where to deliver the
+// finished image.
+type TileJob struct {
+	Zoom, X, Y int
+	MaxIter    int
+	Palette    string
+	ctx        context.Context
+	out        chan<- tileResult
+}
+
+// tileResult carries renderTile's outcome back across the job channel,
+// since a request can now fail with ctx.Err() if the client pans away
+// mid-render.
+type tileResult struct {
+	img *image.RGBA
+	err error
+}
+
+func mandelbrot(c complex128, maxIter int) float64 {
+	n, z := mandel.Iterate(real(c), imag(c), maxIter)
+	if n == maxIter {
+		return 1.0
+	}
+	mu := palette.SmoothIterCount(n, z)
+	if mu > float64(maxIter) {
+		mu = float64(maxIter)
+	}
+	return mu / float64(maxIter)
+}
+
+// tileRange returns the complex-plane rectangle covered by tile (z,x,y)
+// under the slippy-map convention: zoom level z divides the world into
+// 2^z tiles per axis.
+func tileRange(zoom, x, y int) (xRange, yRange [2]float64) {
+	tilesPerAxis := float64(int(1) << uint(zoom))
+	tileWorld := worldSize / tilesPerAxis
+	xRange = [2]float64{worldRe0 + float64(x)*tileWorld, worldRe0 + float64(x+1)*tileWorld}
+	yRange = [2]float64{worldIm0 + float64(y)*tileWorld, worldIm0 + float64(y+1)*tileWorld}
+	return xRange, yRange
+}
+
+// renderTile renders j in coarse-to-fine passes via package progressive, so
+// a tile that's expensive at high maxIter still produces a recognizable
+// preview quickly. ctx is checked before each row of blocks in every pass; if the client disconnects
+// (request context canceled) a partially-refined tile is abandoned and
+// ctx.Err() is returned instead of finishing the render.
+func renderTile(j TileJob) (*image.RGBA, error) {
+	pal, ok := palette.Get(j.Palette)
+	if !ok {
+		pal, _ = palette.Get("hippi")
+	}
+
+	xRange, yRange := tileRange(j.Zoom, j.X, j.Y)
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+
+	ctx := j.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	pixel := func(c complex128) float64 { return mandelbrot(c, j.MaxIter) }
+	if err := progressive.Render(ctx, img, xRange, yRange, pal, pixel, nil); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// workerTile pulls individual tile jobs until the jobs channel is closed,
+// sending each rendered tile back on the job's out channel.
+func workerTile(jobs <-chan TileJob, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		img, err := renderTile(j)
+		j.out <- tileResult{img: img, err: err}
+	}
+}
+
+type tileKey struct {
+	zoom, x, y int
+	maxIter    int
+	palette    string
+}
+
+// tileCache is a small LRU keyed by (z,x,y,maxIter,palette) so repeated
+// pans over the same view don't re-render identical tiles.
+type tileCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	entries  map[tileKey]*list.Element
+}
+
+type cacheEntry struct {
+	key tileKey
+	png []byte
+}
+
+func newTileCache(capacity int) *tileCache {
+	return &tileCache{
+		capacity: capacity,
+		ll:       list.New(),
+		entries:  make(map[tileKey]*list.Element),
+	}
+}
+
+func (c *tileCache) get(key tileKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*cacheEntry).png, true
+	}
+	return nil, false
+}
+
+func (c *tileCache) put(key tileKey, png []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*cacheEntry).png = png
+		return
+	}
+	el := c.ll.PushFront(&cacheEntry{key: key, png: png})
+	c.entries[key] = el
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
+
+// Server renders Mandelbrot tiles on demand behind an LRU cache, feeding
+// requests into a shared worker pool rather than spawning a goroutine per
+// request.
+type Server struct {
+	jobs    chan TileJob
+	cache   *tileCache
+	maxIter int
+}
+
+// NewServer starts the worker pool and returns a Server ready to be
+// registered with an http.ServeMux. defaultMaxIter is used for requests
+// that don't override it via the "iter" query parameter.
+func NewServer(defaultMaxIter, cacheTiles int) *Server {
+	s := &Server{
+		jobs:    make(chan TileJob),
+		cache:   newTileCache(cacheTiles),
+		maxIter: defaultMaxIter,
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go workerTile(s.jobs, &wg)
+	}
+	return s
+}
+
+// ServeHTTP implements /tile/{z}/{x}/{y}.png, returning a 256x256 PNG.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var zoom, x, y int
+	var ext string
+	if _, err := fmt.Sscanf(r.URL.Path, "/tile/%d/%d/%d.%s", &zoom, &x, &y, &ext); err != nil {
+		http.Error(w, "expected /tile/{z}/{x}/{y}.png", http.StatusBadRequest)
+		return
+	}
+
+	maxIter := s.maxIter
+	if iterParam := r.URL.Query().Get("iter"); iterParam != "" {
+		if n, err := strconv.Atoi(iterParam); err == nil && n > 0 {
+			maxIter = n
+		}
+	}
+	paletteName := r.URL.Query().Get("palette")
+	if paletteName == "" {
+		paletteName = "hippi"
+	}
+
+	key := tileKey{zoom: zoom, x: x, y: y, maxIter: maxIter, palette: paletteName}
+	if cached, ok := s.cache.get(key); ok {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(cached)
+		return
+	}
+
+	out := make(chan tileResult, 1)
+	s.jobs <- TileJob{Zoom: zoom, X: x, Y: y, MaxIter: maxIter, Palette: paletteName, ctx: r.Context(), out: out}
+	res := <-out
+	if res.err != nil {
+		// The client disconnected or the request was otherwise canceled
+		// mid-render; nothing to write back.
+		return
+	}
+	img := res.img
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.cache.put(key, buf.Bytes())
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// ListenAndServe registers the tile handler on a fresh mux and blocks
+// serving on addr (e.g. ":8080").
+func ListenAndServe(addr string, defaultMaxIter, cacheTiles int) error {
+	mux := http.NewServeMux()
+	mux.Handle("/tile/", NewServer(defaultMaxIter, cacheTiles))
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,69 @@
+package tileserver
+
+import "testing"
+
+func key(n int) tileKey { return tileKey{zoom: 0, x: n, y: 0, maxIter: 100, palette: "hippi"} }
+
+func TestTileCacheGetMissOnEmptyCache(t *testing.T) {
+	c := newTileCache(2)
+	if _, ok := c.get(key(0)); ok {
+		t.Fatal("get on empty cache should miss")
+	}
+}
+
+func TestTileCachePutThenGetHits(t *testing.T) {
+	c := newTileCache(2)
+	c.put(key(0), []byte("tile0"))
+	got, ok := c.get(key(0))
+	if !ok || string(got) != "tile0" {
+		t.Fatalf("get(key(0)) = (%q, %v), want (\"tile0\", true)", got, ok)
+	}
+}
+
+func TestTileCacheEvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	c := newTileCache(2)
+	c.put(key(0), []byte("tile0"))
+	c.put(key(1), []byte("tile1"))
+	c.put(key(2), []byte("tile2")) // over capacity: should evict key(0), the oldest
+
+	if _, ok := c.get(key(0)); ok {
+		t.Error("key(0) should have been evicted once the cache exceeded capacity")
+	}
+	if _, ok := c.get(key(1)); !ok {
+		t.Error("key(1) should still be cached")
+	}
+	if _, ok := c.get(key(2)); !ok {
+		t.Error("key(2) should still be cached")
+	}
+}
+
+func TestTileCacheGetRefreshesRecency(t *testing.T) {
+	c := newTileCache(2)
+	c.put(key(0), []byte("tile0"))
+	c.put(key(1), []byte("tile1"))
+
+	c.get(key(0)) // touch key(0) so it's no longer the least-recently-used entry
+
+	c.put(key(2), []byte("tile2")) // over capacity: should now evict key(1), not key(0)
+
+	if _, ok := c.get(key(1)); ok {
+		t.Error("key(1) should have been evicted; key(0) was touched more recently")
+	}
+	if _, ok := c.get(key(0)); !ok {
+		t.Error("key(0) should still be cached after being refreshed by get")
+	}
+}
+
+func TestTileCachePutOverwritesExistingKeyWithoutGrowing(t *testing.T) {
+	c := newTileCache(2)
+	c.put(key(0), []byte("tile0"))
+	c.put(key(0), []byte("tile0-v2"))
+
+	got, ok := c.get(key(0))
+	if !ok || string(got) != "tile0-v2" {
+		t.Fatalf("get(key(0)) = (%q, %v), want (\"tile0-v2\", true)", got, ok)
+	}
+	if c.ll.Len() != 1 {
+		t.Errorf("ll.Len() = %d, want 1 (overwrite shouldn't add a new entry)", c.ll.Len())
+	}
+}